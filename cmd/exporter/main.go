@@ -1,18 +1,24 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"confluence-exporter/internal/api"
 	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/converter"
 	"confluence-exporter/internal/models"
 	"confluence-exporter/internal/output"
+	"confluence-exporter/internal/state"
 	"confluence-exporter/pkg/utils"
 )
 
@@ -58,85 +64,524 @@ func (pt *ProgressTracker) GetStats() string {
 		elapsed, pt.lastPagesPerMinute, pt.processedPages, pt.totalPages)
 }
 
-func exportSpace(client *api.ConfluenceClient, spaceKey string, cfg *config.Config, progress *ProgressTracker, handler output.Handler) error {
-	// Get all pages from specified space
-	log.Printf("🔍 Fetching pages from space: %s", spaceKey)
-	pages, err := client.GetPages(spaceKey)
+// rateStats describes the current client-side request rate, if one is configured
+func rateStats(source PageSource) string {
+	client, ok := source.(*api.ConfluenceClient)
+	if !ok || client.Limiter == nil {
+		return ""
+	}
+	return fmt.Sprintf(" | 🚦 %.1f req/s", client.Limiter.Rate())
+}
+
+// checkpointInterval is the number of saved pages after which the
+// checkpoint is written while exporting a page tree
+const checkpointInterval = 25
+
+// exportRun holds everything shared by the pages of one export run
+type exportRun struct {
+	source      PageSource
+	cfg         *config.Config
+	handler     output.Handler
+	tracker     *state.Tracker
+	checkpoint  *state.Checkpoint
+	incremental bool
+	// links resolves links between pages, nil unless PreserveLinks is set
+	links *converter.PageIndex
+	// versions stores the version history, nil unless IncludeVersions is set
+	// and the handler supports it
+	versions output.VersionHandler
+}
+
+// pageLister lists pages batch by batch starting at a pagination offset,
+// like PageSource.ListPages and PageSource.SearchPages
+type pageLister func(start int, fn func(pages []models.Page, next int) error) error
+
+func (r *exportRun) exportSpace(spaceKey string, progress *ProgressTracker) error {
+	list := func(start int, fn func([]models.Page, int) error) error {
+		return r.source.ListPages(spaceKey, start, fn)
+	}
+	return r.exportListing(state.SpaceScope(spaceKey), "Space: "+spaceKey, list, progress)
+}
+
+// exportQuery exports all pages matching a CQL query
+func (r *exportRun) exportQuery(cql string, progress *ProgressTracker) error {
+	list := func(start int, fn func([]models.Page, int) error) error {
+		return r.source.SearchPages(cql, start, fn)
+	}
+	return r.exportListing(state.QueryScope(cql), "CQL", list, progress)
+}
+
+// exportListing exports the pages returned by list, checkpointing after each
+// batch so an interrupted run can continue at the last offset
+func (r *exportRun) exportListing(scope, label string, list pageLister, progress *ProgressTracker) error {
+	sc := r.checkpoint.Scope(scope)
+	done, failed := r.tracker.Restore(scope, sc)
+	if len(failed) > 0 {
+		r.retryPages(scope, failed, done)
+	}
+	if sc.Done {
+		log.Printf("⏭️  %s was completed by the interrupted run, skipping", label)
+		r.tracker.CompleteScope(scope)
+		return nil
+	}
+	if len(done) > 0 {
+		log.Printf("⏩ Resuming %s at offset %d (%d pages already done)", label, sc.Offset, len(done))
+	}
+
+	// Create a progress tracker for this listing's pages
+	pageProgress := NewProgressTracker(0)
+	pageProgress.startTime = time.Now()
+
+	// Get the pages batch by batch, checkpointing after each batch
+	log.Printf("🔍 Fetching pages (%s)", label)
+	listed, selected := 0, 0
+	err := list(sc.Offset, func(pages []models.Page, next int) error {
+		listed += len(pages)
+		pages = r.selectPages(scope, withoutPages(pages, done))
+		selected += len(pages)
+		pageProgress.totalPages += len(pages)
+
+		// Process pages concurrently, saving them in their original order
+		r.savePages(scope, pages, func(page models.Page) {
+			// Update and display progress for this listing
+			pageProgress.Update()
+			fmt.Printf("\r%s | %s | Pages: %s%s", progress.GetProgressBar(), label, pageProgress.GetStats(), rateStats(r.source))
+		})
+
+		sc.Offset = next
+		r.saveCheckpoint()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch pages: %w", err)
+	}
+
+	fmt.Println()
+	log.Printf("📚 Found %d pages (%s)", listed+len(done), label)
+	if r.incremental {
+		log.Printf("🔁 %d of %d pages are new or changed since the last run", selected, listed)
+	}
+
+	sc.Done = true
+	r.tracker.CompleteScope(scope)
+	r.saveCheckpoint()
+	return nil
+}
+
+// retryPages exports the pages that failed in the interrupted run again. The
+// listing continues after the batches they were listed in, so they are
+// fetched by ID and added to done.
+func (r *exportRun) retryPages(scope string, failed []state.PageState, done map[string]bool) {
+	log.Printf("🔄 Retrying %d pages that failed in the interrupted run", len(failed))
+	sc := r.checkpoint.Scope(scope)
+
+	var pages []models.Page
+	for _, entry := range failed {
+		done[entry.ID] = true
+		page, err := r.source.GetPage(entry.ID)
+		if err != nil {
+			log.Printf("❌ Failed to fetch page %s again: %v", entry.Title, err)
+			r.tracker.Observe(scope, entry.Page())
+			sc.Failed = append(sc.Failed, entry)
+			continue
+		}
+		pages = append(pages, *page)
+	}
+
+	r.savePages(scope, r.selectPages(scope, pages), func(models.Page) {})
+	r.saveCheckpoint()
+}
+
+// pageRef describes a page for link resolution
+func (r *exportRun) pageRef(page models.Page) converter.PageRef {
+	ref := converter.PageRef{ID: page.ID, Title: page.Title, SpaceKey: page.SpaceKey, ParentID: page.ParentID, URL: page.URL}
+	if pathHandler, ok := r.handler.(output.PathHandler); ok {
+		ref.Path = pathHandler.PagePath(page)
+	}
+	return ref
+}
+
+// indexPages adds pages to the link index
+func (r *exportRun) indexPages(pages []models.Page) {
+	if r.links == nil {
+		return
+	}
+	for _, page := range pages {
+		r.links.Add(r.pageRef(page))
+	}
+}
+
+// indexListing adds the pages returned by list to the link index. The index
+// is built before the export, so links to pages that are exported later can
+// be resolved.
+func (r *exportRun) indexListing(label string, list func() ([]models.Page, error)) {
+	if r.links == nil {
+		return
+	}
+	pages, err := list()
 	if err != nil {
-		return fmt.Errorf("failed to fetch pages: %v", err)
+		log.Printf("⚠️  Failed to index pages for link resolution (%s): %v", label, err)
+		return
+	}
+	r.indexPages(pages)
+	log.Printf("🔗 Indexed %d pages for link resolution (%s)", len(pages), label)
+}
+
+// withoutPages returns pages without the pages whose ID is in exclude
+func withoutPages(pages []models.Page, exclude map[string]bool) []models.Page {
+	if len(exclude) == 0 {
+		return pages
 	}
 
-	log.Printf("📚 Found %d pages to export in space %s", len(pages), spaceKey)
+	var remaining []models.Page
+	for _, page := range pages {
+		if !exclude[page.ID] {
+			remaining = append(remaining, page)
+		}
+	}
+	return remaining
+}
 
-	// Create a progress tracker for this space's pages
-	spaceProgress := NewProgressTracker(len(pages))
-	spaceProgress.startTime = time.Now()
+// selectPages compares pages with the previous run and returns the pages to
+// export. In incremental mode, pages whose version did not change are skipped.
+func (r *exportRun) selectPages(scope string, pages []models.Page) []models.Page {
+	sc := r.checkpoint.Scope(scope)
 
-	// Process each page
+	var selected []models.Page
 	for _, page := range pages {
-		// Update and display progress for this space
-		spaceProgress.Update()
-		fmt.Printf("\r%s | Space: %s | Pages: %s", progress.GetProgressBar(), spaceKey, spaceProgress.GetStats())
+		if r.tracker.Observe(scope, page) == state.Unchanged && r.incremental {
+			r.tracker.Saved(scope, page)
+			sc.Pages = append(sc.Pages, state.NewPageState(scope, page))
+			continue
+		}
 
-		// Save page using the output handler
-		if err := handler.SavePage(client, page, spaceKey); err != nil {
+		// Relocate artifacts of moved or renamed pages before they are saved again
+		if from, moved := r.tracker.MovedFrom(page); moved {
+			if err := r.handler.MovePage(from.Page(), page); err != nil {
+				log.Printf("⚠️  Failed to move page %s: %v", page.Title, err)
+			}
+		}
+		selected = append(selected, page)
+	}
+
+	return selected
+}
+
+// preparedPage is a page that is ready to be handed to an output handler
+type preparedPage struct {
+	page     models.Page
+	markdown string
+	versions []preparedVersion
+	err      error
+}
+
+// preparedVersion is a historical version of a page converted to Markdown
+type preparedVersion struct {
+	version  models.PageVersion
+	markdown string
+}
+
+// savePages prepares pages on up to ConcurrentRequests workers and passes them
+// to the output handler in their original order. onSaved is called after each
+// page, whether saving succeeded or not.
+func (r *exportRun) savePages(scope string, pages []models.Page, onSaved func(models.Page)) {
+	sc := r.checkpoint.Scope(scope)
+
+	saved := 0
+	runOrdered(pages, r.cfg.Export.ConcurrentRequests, r.preparePage, func(page models.Page, prepared preparedPage) {
+		onSaved(page)
+
+		err := prepared.err
+		if err == nil {
+			r.saveVersions(prepared)
+			err = r.handler.SavePage(prepared.page, prepared.markdown)
+		}
+		if err != nil {
 			fmt.Println() // New line for error message
 			log.Printf("❌ Failed to save page %s: %v", page.Title, err)
-			continue
+			sc.Failed = append(sc.Failed, state.NewPageState(scope, page))
+			return
+		}
+		r.tracker.Saved(scope, page)
+		sc.Pages = append(sc.Pages, state.NewPageState(scope, page))
+
+		if saved++; saved%checkpointInterval == 0 {
+			r.saveCheckpoint()
+		}
+	})
+}
+
+// saveCheckpoint flushes the output handler and records the progress made so far
+func (r *exportRun) saveCheckpoint() {
+	if checkpointer, ok := r.handler.(output.Checkpointer); ok {
+		marker, err := checkpointer.Checkpoint()
+		if err != nil {
+			log.Printf("⚠️  Failed to checkpoint output: %v", err)
+			return
 		}
+		r.checkpoint.Output = marker
 	}
 
-	return nil
+	if err := r.checkpoint.Save(); err != nil {
+		log.Printf("⚠️  Failed to save checkpoint: %v", err)
+	}
 }
 
-// fetchPageTree retrieves a page and all of its descendant pages
-func fetchPageTree(client *api.ConfluenceClient, rootPageID string) ([]models.Page, error) {
-	rootPage, err := client.GetPage(rootPageID)
+// preparePage converts a page to Markdown, collects its links and downloads
+// its embedded images, and all of its attachments if IncludeAttachments is set
+func (r *exportRun) preparePage(page models.Page) preparedPage {
+	images, err := converter.ImageAttachments(page.Content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch root page %s: %w", rootPageID, err)
+		return preparedPage{page: page, err: fmt.Errorf("failed to convert page %s: %w", page.ID, err)}
 	}
 
-	allPages := []models.Page{*rootPage}
+	var links map[string]string
+	if r.cfg.Export.IncludeAttachments || len(images) > 0 {
+		links, err = r.saveAttachments(&page, images)
+		if err != nil {
+			return preparedPage{page: page, err: err}
+		}
+	}
+
+	opts := converter.Options{
+		Index:            r.links,
+		Page:             r.pageRef(page),
+		Attachments:      links,
+		HTMLTables:       r.cfg.Export.Format.HTMLTableFallback,
+		AdmonitionFlavor: r.cfg.Export.Format.AdmonitionFlavor,
+		ImageFormat:      r.cfg.Export.Format.ImageFormat,
+	}
+	markdown, err := converter.ConvertToMarkdown(page.Content, opts)
+	if err != nil {
+		return preparedPage{page: page, err: fmt.Errorf("failed to convert page %s: %w", page.ID, err)}
+	}
+	if page.Links, err = converter.PageLinks(page.Content, opts); err != nil {
+		return preparedPage{page: page, err: fmt.Errorf("failed to convert page %s: %w", page.ID, err)}
+	}
 
-	children, err := client.GetChildPages(rootPage.ID)
+	prepared := preparedPage{page: page, markdown: markdown}
+	if r.versions != nil {
+		prepared.versions = r.prepareVersions(page, opts)
+	}
+	return prepared
+}
+
+// prepareVersions fetches and converts the historical versions of a page,
+// oldest first: the latest MaxVersions of them, or all if it is 0. In
+// incremental mode, versions exported by the previous run are skipped.
+// Versions that can't be fetched or converted are logged and left out.
+func (r *exportRun) prepareVersions(page models.Page, opts converter.Options) []preparedVersion {
+	history, err := r.source.GetPageVersions(page.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch child pages for %s: %w", rootPageID, err)
+		log.Printf("⚠️  Failed to fetch versions of page %s: %v", page.Title, err)
+		return nil
+	}
+
+	// The current version is exported as the page itself
+	var numbers []int
+	for _, version := range history {
+		if version.Number < page.Version {
+			numbers = append(numbers, version.Number)
+		}
 	}
+	sort.Ints(numbers)
+	if limit := r.cfg.Export.MaxVersions; limit > 0 && len(numbers) > limit {
+		numbers = numbers[len(numbers)-limit:]
+	}
+
+	// The previous run exported older versions and the then current one as the page
+	exported := 0
+	if r.incremental {
+		exported = r.tracker.PreviousVersion(page.ID) - 1
+	}
+
+	var versions []preparedVersion
+	for _, number := range numbers {
+		if number <= exported {
+			continue
+		}
 
-	for _, child := range children {
-		subtree, err := collectChildPages(client, child)
+		version, err := r.source.GetPageVersion(page.ID, number)
 		if err != nil {
-			return nil, err
+			log.Printf("⚠️  Failed to fetch version %d of page %s: %v", number, page.Title, err)
+			continue
+		}
+		markdown, err := converter.ConvertToMarkdown(version.Content, r.versionOptions(page, number, opts))
+		if err != nil {
+			log.Printf("⚠️  Failed to convert version %d of page %s: %v", number, page.Title, err)
+			continue
 		}
-		allPages = append(allPages, subtree...)
+		versions = append(versions, preparedVersion{version: *version, markdown: markdown})
 	}
+	return versions
+}
 
-	return allPages, nil
+// versionOptions returns the converter options of a historical version. For
+// handlers that write versions to files of their own, relative links start
+// at the version's file instead of the page's.
+func (r *exportRun) versionOptions(page models.Page, number int, opts converter.Options) converter.Options {
+	pathHandler, ok := r.handler.(output.VersionPathHandler)
+	if !ok || opts.Page.Path == "" {
+		return opts
+	}
+
+	pageDir := filepath.Dir(opts.Page.Path)
+	opts.Page.Path = pathHandler.VersionPath(page, number)
+	versionDir := filepath.Dir(opts.Page.Path)
+
+	attachments := make(map[string]string, len(opts.Attachments))
+	for name, link := range opts.Attachments {
+		if !strings.Contains(link, "://") && !strings.HasPrefix(link, "/") {
+			if rel, err := filepath.Rel(versionDir, filepath.Join(pageDir, filepath.FromSlash(link))); err == nil {
+				link = filepath.ToSlash(rel)
+			}
+		}
+		attachments[name] = link
+	}
+	opts.Attachments = attachments
+	return opts
+}
+
+// saveVersions passes the historical versions of a page to the handler, oldest first
+func (r *exportRun) saveVersions(prepared preparedPage) {
+	for _, v := range prepared.versions {
+		if err := r.versions.SaveVersion(prepared.page, v.version, v.markdown); err != nil {
+			log.Printf("⚠️  Failed to save version %d of page %s: %v", v.version.Number, prepared.page.Title, err)
+		}
+	}
+}
+
+// saveAttachments downloads the attachments of a page into the handler's
+// attachment directory: all of them if IncludeAttachments is set, otherwise
+// only the embedded images. It returns the link targets of the attachments by
+// file name, relative to the page's file. Handlers without an attachment
+// directory get links to the files in Confluence and, with
+// IncludeAttachments, the attachment metadata only.
+func (r *exportRun) saveAttachments(page *models.Page, images []string) (map[string]string, error) {
+	attachments, err := r.source.GetAttachments(page.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments for %s: %w", page.ID, err)
+	}
+	if r.cfg.Export.IncludeAttachments {
+		page.Attachments = attachments
+	}
+
+	embedded := make(map[string]bool, len(images))
+	for _, name := range images {
+		embedded[name] = true
+	}
+
+	links := make(map[string]string)
+	attachmentHandler, ok := r.handler.(output.AttachmentHandler)
+	if !ok {
+		for _, attachment := range attachments {
+			if embedded[attachment.FileName] {
+				links[attachment.FileName] = r.source.GetBaseURL() + attachment.DownloadURL
+			}
+		}
+		return links, nil
+	}
+
+	if len(attachments) == 0 {
+		return links, nil
+	}
+
+	dir := attachmentHandler.AttachmentDir(*page)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create attachment directory %s: %w", dir, err)
+	}
+
+	for _, attachment := range attachments {
+		if !r.cfg.Export.IncludeAttachments && !embedded[attachment.FileName] {
+			continue
+		}
+
+		outputPath := filepath.Join(dir, output.SafeFilename(attachment.FileName))
+		if err := downloadAttachment(r.source, attachment, outputPath); err != nil {
+			log.Printf("⚠️  Failed to download attachment %s of page %s: %v", attachment.FileName, page.Title, err)
+			continue
+		}
+		links[attachment.FileName] = r.attachmentLink(*page, attachment, outputPath)
+	}
+
+	return links, nil
+}
+
+// attachmentLink returns the link target of a downloaded attachment, relative
+// to the page's file if the handler writes one
+func (r *exportRun) attachmentLink(page models.Page, attachment models.Attachment, outputPath string) string {
+	if pathHandler, ok := r.handler.(output.PathHandler); ok {
+		pagePath := filepath.Join(r.cfg.Export.OutputDir, pathHandler.PagePath(page))
+		if rel, err := filepath.Rel(filepath.Dir(pagePath), outputPath); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return r.source.GetBaseURL() + attachment.DownloadURL
+}
+
+// fetchPageTree retrieves a page and all of its descendant pages. Child pages
+// are discovered with up to workers concurrent requests; the result is in
+// depth-first order regardless of the order in which requests complete.
+func fetchPageTree(source PageSource, rootPageID string, workers int) ([]models.Page, error) {
+	rootPage, err := source.GetPage(rootPageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch root page %s: %w", rootPageID, err)
+	}
+
+	return collectChildPages(source, *rootPage, newSemaphore(workers))
 }
 
 // collectChildPages recursively collects descendant pages for the provided page
-func collectChildPages(client *api.ConfluenceClient, page models.Page) ([]models.Page, error) {
+func collectChildPages(source PageSource, page models.Page, sem semaphore) ([]models.Page, error) {
 	pages := []models.Page{page}
 
-	children, err := client.GetChildPages(page.ID)
+	sem.acquire()
+	children, err := source.GetChildPages(page.ID)
+	sem.release()
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch child pages for %s: %w", page.ID, err)
 	}
 
-	for _, child := range children {
-		subtree, err := collectChildPages(client, child)
-		if err != nil {
-			return nil, err
+	subtrees := make([][]models.Page, len(children))
+	errs := make([]error, len(children))
+
+	var wg sync.WaitGroup
+	for i, child := range children {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			subtrees[i], errs[i] = collectChildPages(source, child, sem)
+		}()
+	}
+	wg.Wait()
+
+	for i := range children {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
-		pages = append(pages, subtree...)
+		pages = append(pages, subtrees[i]...)
 	}
 
 	return pages, nil
 }
 
 func main() {
+	// Subcommands work on the output of an earlier export
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "search":
+			runSearch(os.Args[2:])
+			return
+		case "similar":
+			runSimilar(os.Args[2:])
+			return
+		}
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", "config.json", "Path to configuration file")
+	incremental := flag.Bool("incremental", false, "Only export pages that are new or changed since the last run")
+	resume := flag.Bool("resume", false, "Continue an interrupted export from its last checkpoint")
 	flag.Parse()
 
 	// Load configuration
@@ -152,29 +597,82 @@ func main() {
 	log.Printf("🚀 Starting Confluence export process...")
 
 	// Initialize output handler
-	handler, err := output.NewHandler(cfg.Export.OutputType, cfg.Export.OutputDir, cfg.Export.IncludeAttachments)
+	handler, err := output.NewHandler(cfg.Export)
 	if err != nil {
 		log.Fatalf("Failed to initialize output handler: %v", err)
 	}
-	defer handler.Close()
 
-	if err := handler.Initialize(); err != nil {
+	// Load the checkpoint of an interrupted run
+	checkpointPath := filepath.Join(cfg.Export.OutputDir, state.CheckpointFileName)
+	var checkpoint *state.Checkpoint
+	if *resume {
+		checkpoint, err = state.LoadCheckpoint(checkpointPath)
+		if err != nil {
+			log.Fatalf("Failed to load checkpoint: %v", err)
+		}
+		if checkpoint == nil {
+			log.Printf("⚠️  No checkpoint found at %s, starting a new export", checkpointPath)
+		} else {
+			log.Printf("⏩ Resuming export from checkpoint of %s", checkpoint.UpdatedAt.Local().Format(time.RFC1123))
+		}
+	}
+
+	if checkpointer, ok := handler.(output.Checkpointer); ok && checkpoint != nil {
+		err = checkpointer.Resume(checkpoint.Output)
+	} else {
+		err = handler.Initialize()
+	}
+	if err != nil {
 		log.Fatalf("Failed to initialize output: %v", err)
 	}
+	if checkpoint == nil {
+		checkpoint = state.NewCheckpoint(checkpointPath)
+	}
 
-	// Initialize Confluence client
-	client := api.NewConfluenceClient(
-		cfg.Confluence.BaseURL,
-		cfg.Confluence.Username,
-		cfg.Confluence.APIToken,
-	)
+	// Read pages from the REST API or from a space export
+	source, err := newPageSource(cfg.Confluence)
+	if err != nil {
+		log.Fatalf("Failed to open page source: %v", err)
+	}
+	if closer, ok := source.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if cfg.Confluence.SpaceExport != "" {
+		log.Printf("📦 Reading pages from space export %s", cfg.Confluence.SpaceExport)
+	}
+
+	// Load the sync state of the previous run
+	statePath := filepath.Join(cfg.Export.OutputDir, state.FileName)
+	previous, err := state.Load(statePath)
+	if err != nil {
+		log.Fatalf("Failed to load sync state: %v", err)
+	}
+
+	run := &exportRun{
+		source:      source,
+		cfg:         cfg,
+		handler:     handler,
+		tracker:     state.NewTracker(previous),
+		checkpoint:  checkpoint,
+		incremental: *incremental,
+	}
+	if cfg.Export.Format.PreserveLinks {
+		run.links = converter.NewPageIndex(cfg.Confluence.BaseURL)
+	}
+	if cfg.Export.IncludeVersions {
+		if versionHandler, ok := handler.(output.VersionHandler); ok {
+			run.versions = versionHandler
+		} else {
+			log.Printf("⚠️  Output type %s doesn't store page versions, skipping the version history", cfg.Export.OutputType)
+		}
+	}
 
 	var progress *ProgressTracker
 	summaryLabel := "Total spaces processed"
 
 	if cfg.Export.PageID != "" {
 		log.Printf("📄 Root page ID provided (%s), exporting page tree...", cfg.Export.PageID)
-		pages, err := fetchPageTree(client, cfg.Export.PageID)
+		pages, err := fetchPageTree(source, cfg.Export.PageID, cfg.Export.ConcurrentRequests)
 		if err != nil {
 			log.Fatalf("Failed to fetch page tree: %v", err)
 		}
@@ -184,29 +682,56 @@ func main() {
 		}
 
 		rootPage := pages[0]
-		log.Printf("📚 Found %d pages to export under root page %s (%s)", len(pages), rootPage.Title, cfg.Export.PageID)
+		log.Printf("📚 Found %d pages under root page %s (%s)", len(pages), rootPage.Title, cfg.Export.PageID)
+		run.indexPages(pages)
+
+		scope := state.TreeScope(cfg.Export.PageID)
+		// Failed pages are listed with the tree again and exported like the others
+		done, _ := run.tracker.Restore(scope, checkpoint.Scope(scope))
+		if len(done) > 0 {
+			log.Printf("⏩ Resuming page tree, %d pages already done", len(done))
+		}
+
+		listed := len(pages) - len(done)
+		pages = run.selectPages(scope, withoutPages(pages, done))
+		if run.incremental {
+			log.Printf("🔁 %d of %d pages are new or changed since the last run", len(pages), listed)
+		}
 
 		progress = NewProgressTracker(len(pages))
 		summaryLabel = "Total pages processed"
 
-		for _, page := range pages {
+		run.savePages(scope, pages, func(page models.Page) {
 			progress.Update()
-			fmt.Printf("\r%s | Page tree: %s | %s", progress.GetProgressBar(), rootPage.Title, progress.GetStats())
+			fmt.Printf("\r%s | Page tree: %s | %s%s", progress.GetProgressBar(), rootPage.Title, progress.GetStats(), rateStats(source))
+		})
 
-			if err := handler.SavePage(client, page, page.SpaceKey); err != nil {
-				fmt.Println()
-				log.Printf("❌ Failed to save page %s: %v", page.Title, err)
-				continue
-			}
-		}
+		checkpoint.Scope(scope).Done = true
+		run.tracker.CompleteScope(scope)
+		run.saveCheckpoint()
 
 		log.Printf("✅ Successfully exported page tree rooted at %s (%s)", rootPage.Title, cfg.Export.PageID)
+	} else if cfg.Export.CQL != "" {
+		log.Printf("🔎 CQL query provided, exporting matching pages: %s", cfg.Export.CQL)
+
+		run.indexListing("CQL", func() ([]models.Page, error) {
+			return source.SearchPageSummaries(cfg.Export.CQL)
+		})
+
+		progress = NewProgressTracker(1)
+		summaryLabel = "Total queries processed"
+
+		if err := run.exportQuery(cfg.Export.CQL, progress); err != nil {
+			log.Fatalf("Failed to export CQL query: %v", err)
+		}
+		progress.Update()
+		log.Printf("✅ Successfully exported pages matching the CQL query")
 	} else {
 		// Get all spaces if no specific space key is provided
 		var spaces []models.Space
 		if cfg.Export.SpaceKey == "" {
 			log.Printf("🌍 No space key provided, fetching all accessible spaces...")
-			spaces, err = client.GetSpaces()
+			spaces, err = source.GetSpaces()
 			if err != nil {
 				log.Fatalf("Failed to fetch spaces: %v", err)
 			}
@@ -216,6 +741,12 @@ func main() {
 			spaces = []models.Space{{Key: cfg.Export.SpaceKey}}
 		}
 
+		for _, space := range spaces {
+			run.indexListing("space "+space.Key, func() ([]models.Page, error) {
+				return source.ListPageSummaries(space.Key)
+			})
+		}
+
 		// Initialize progress tracker with total spaces
 		progress = NewProgressTracker(len(spaces))
 		progress.totalPages = len(spaces) // Use spaces count for progress bar
@@ -223,7 +754,15 @@ func main() {
 		// Export each space
 		for _, space := range spaces {
 			log.Printf("🚀 Starting export of space: %s", space.Key)
-			if err := exportSpace(client, space.Key, cfg, progress, handler); err != nil {
+			if err := run.exportSpace(space.Key, progress); err != nil {
+				// Bad credentials fail every space, a missing permission only this one
+				if errors.Is(err, api.ErrUnauthorized) {
+					log.Fatalf("Confluence rejected the credentials for space %s: %v", space.Key, err)
+				}
+				if errors.Is(err, api.ErrForbidden) {
+					log.Printf("🔒 No permission to read space %s, skipping: %v", space.Key, err)
+					continue
+				}
 				log.Printf("❌ Failed to export space %s: %v", space.Key, err)
 				continue
 			}
@@ -233,57 +772,72 @@ func main() {
 		}
 	}
 
+	// Remove pages that were deleted in Confluence from the output
+	nextState, report := run.tracker.Finish()
+	for _, page := range report.Deleted {
+		if err := handler.RemovePage(page.Page()); err != nil {
+			log.Printf("⚠️  Failed to remove deleted page %s: %v", page.Title, err)
+		}
+	}
+
+	// Flush and close the output handler
+	if err := handler.Close(); err != nil {
+		log.Fatalf("Failed to finalize output: %v", err)
+	}
+
 	// Print final progress bar
-	fmt.Println("\n")
+	fmt.Print("\n\n")
 	log.Printf("🎉 Export completed successfully!")
+	fmt.Printf("✨ Export completed successfully! %s\n", handler.Location())
+
+	// Persist the sync state for the next run
+	if err := nextState.Save(statePath); err != nil {
+		log.Printf("⚠️  Failed to save sync state: %v", err)
+	}
 
-	// Print output location based on type
-	switch cfg.Export.OutputType {
-	case "file":
-		fmt.Printf("✨ Export completed successfully! Files saved to %s\n", cfg.Export.OutputDir)
-	case "meilisearch":
-		fmt.Printf("✨ Export completed successfully! MeiliSearch JSON saved to %s/confluence_pages_meilisearch.json\n", cfg.Export.OutputDir)
-	case "singletxt":
-		fmt.Printf("✨ Export completed successfully! Single text file saved to %s/confluence_export.txt\n", cfg.Export.OutputDir)
-	default:
-		fmt.Printf("✨ Export completed successfully! Data saved to confluence_pages.db\n")
+	// The run is complete, a later --resume starts over
+	if err := checkpoint.Remove(); err != nil {
+		log.Printf("⚠️  Failed to remove checkpoint: %v", err)
 	}
 
 	fmt.Printf("📊 Final statistics:\n")
 	fmt.Printf("   • Total time: %s\n", time.Since(progress.startTime).Round(time.Second))
 	fmt.Printf("   • %s: %d\n", summaryLabel, progress.processedPages)
+	printSyncReport(report)
 }
 
-// getSafeFilename converts a string to a safe filename
-func getSafeFilename(name string) string {
-	// Replace characters that are not allowed in filenames
-	// This is a simplified version, you might need to handle more cases
-	replacer := strings.NewReplacer(
-		"/", "-",
-		"\\", "-",
-		":", "-",
-		"*", "-",
-		"?", "-",
-		"\"", "-",
-		"<", "-",
-		">", "-",
-		"|", "-",
-		" ", "_",
-	)
-	return replacer.Replace(name)
+// printSyncReport counts the pages that changed since the previous run and
+// logs the changed, moved and deleted ones
+func printSyncReport(report state.Report) {
+	fmt.Printf("   • Added pages: %d\n", len(report.Added))
+	fmt.Printf("   • Changed pages: %d\n", len(report.Changed))
+	fmt.Printf("   • Unchanged pages: %d\n", report.Unchanged)
+	fmt.Printf("   • Moved pages: %d\n", len(report.Moved))
+	fmt.Printf("   • Deleted pages: %d\n", len(report.Deleted))
+	for _, page := range report.Deleted {
+		fmt.Printf("     - removed %s (%s, space %s)\n", page.Title, page.ID, page.SpaceKey)
+	}
+
+	// Added pages are only counted, on a first run they are the whole export
+	for _, page := range report.Changed {
+		log.Printf("✏️  Changed: %s (%s, v%d)", page.Title, page.ID, page.Version)
+	}
+	for _, move := range report.Moved {
+		log.Printf("🚚 Moved: %s (%s) -> %s (space %s)", move.From.Title, move.From.ID, move.To.Title, move.To.SpaceKey)
+	}
+	for _, page := range report.Deleted {
+		log.Printf("🗑️  Deleted: %s (%s)", page.Title, page.ID)
+	}
 }
 
 // downloadAttachment downloads and saves an attachment to disk
-func downloadAttachment(client *api.ConfluenceClient, attachment models.Attachment, outputPath string) error {
-	// Construct the full download URL
-	downloadURL := client.GetBaseURL() + attachment.DownloadURL
-
+func downloadAttachment(source PageSource, attachment models.Attachment, outputPath string) error {
 	// Get the file
-	resp, err := client.GetAttachmentContent(downloadURL)
+	content, err := source.OpenAttachment(attachment)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer content.Close()
 
 	// Create the output file
 	out, err := os.Create(outputPath)
@@ -293,6 +847,6 @@ func downloadAttachment(client *api.ConfluenceClient, attachment models.Attachme
 	defer out.Close()
 
 	// Write the content to the file
-	_, err = io.Copy(out, resp.Body)
+	_, err = io.Copy(out, content)
 	return err
 }
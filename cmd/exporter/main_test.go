@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+	"confluence-exporter/internal/output"
+	"confluence-exporter/internal/spaceexport"
+	"confluence-exporter/internal/state"
+)
+
+// fakeSource is a PageSource serving the pages of one space in batches of
+// batchSize
+type fakeSource struct {
+	pages     []models.Page
+	batchSize int
+}
+
+func newFakeSource(spaceKey string, n, batchSize int) *fakeSource {
+	s := &fakeSource{batchSize: batchSize}
+	for i := 1; i <= n; i++ {
+		s.pages = append(s.pages, models.Page{
+			ID:       fmt.Sprint(i),
+			Title:    fmt.Sprintf("Page %d", i),
+			SpaceKey: spaceKey,
+			Version:  1,
+			Content:  fmt.Sprintf("<p>Body of page %d</p>", i),
+		})
+	}
+	return s
+}
+
+func (s *fakeSource) ListPages(spaceKey string, start int, fn func(pages []models.Page, next int) error) error {
+	for start < len(s.pages) {
+		end := min(start+s.batchSize, len(s.pages))
+		if err := fn(s.pages[start:end], end); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func (s *fakeSource) GetPage(pageID string) (*models.Page, error) {
+	for _, page := range s.pages {
+		if page.ID == pageID {
+			return &page, nil
+		}
+	}
+	return nil, fmt.Errorf("page %s not found", pageID)
+}
+
+func (s *fakeSource) GetSpaces() ([]models.Space, error) { return nil, nil }
+func (s *fakeSource) GetChildPages(string) ([]models.Page, error) {
+	return nil, nil
+}
+func (s *fakeSource) ListPageSummaries(string) ([]models.Page, error) { return s.pages, nil }
+func (s *fakeSource) SearchPages(string, int, func([]models.Page, int) error) error {
+	return spaceexport.ErrSearchUnsupported
+}
+func (s *fakeSource) SearchPageSummaries(string) ([]models.Page, error) {
+	return nil, spaceexport.ErrSearchUnsupported
+}
+func (s *fakeSource) GetPageVersions(string) ([]models.PageVersion, error) { return nil, nil }
+func (s *fakeSource) GetPageVersion(string, int) (*models.PageVersion, error) {
+	return nil, errors.New("no versions")
+}
+func (s *fakeSource) GetAttachments(string) ([]models.Attachment, error) { return nil, nil }
+func (s *fakeSource) OpenAttachment(models.Attachment) (io.ReadCloser, error) {
+	return nil, errors.New("no attachments")
+}
+func (s *fakeSource) GetBaseURL() string { return "https://example.atlassian.net/wiki" }
+
+// errCrash stops an export like a killed process
+var errCrash = errors.New("crash")
+
+// crashingHandler writes to a single text file and crashes before saving the
+// page after the first crashAfter pages. What was written so far reaches the
+// disk, like buffers the operating system flushed before the process died.
+type crashingHandler struct {
+	*output.SingleTextHandler
+	crashAfter int
+	saved      int
+}
+
+func (h *crashingHandler) SavePage(page models.Page, markdown string) error {
+	if h.saved == h.crashAfter {
+		h.SingleTextHandler.Close()
+		panic(errCrash)
+	}
+	h.saved++
+	return h.SingleTextHandler.SavePage(page, markdown)
+}
+
+func newTestRun(cfg *config.Config, source PageSource, handler output.Handler, checkpoint *state.Checkpoint) *exportRun {
+	return &exportRun{
+		source:     source,
+		cfg:        cfg,
+		handler:    handler,
+		tracker:    state.NewTracker(state.New()),
+		checkpoint: checkpoint,
+	}
+}
+
+func TestResumeAfterCrashWritesEveryPageOnce(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Export: config.ExportConfig{OutputType: "singletxt", OutputDir: dir, ConcurrentRequests: 2}}
+	checkpointPath := filepath.Join(dir, state.CheckpointFileName)
+	textPath := filepath.Join(dir, output.SingleTextFile)
+	// Two batches of 30 pages: the second one is checkpointed after its
+	// 25th page and the run crashes three pages later
+	source := newFakeSource("TEAM", 60, 30)
+
+	handler, err := output.NewHandler(cfg.Export)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	if err := handler.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	crashing := &crashingHandler{SingleTextHandler: handler.(*output.SingleTextHandler), crashAfter: 58}
+	run := newTestRun(cfg, source, crashing, state.NewCheckpoint(checkpointPath))
+
+	func() {
+		// The panic leaves the workers of the interrupted batch behind,
+		// like the goroutines of a killed process
+		defer func() {
+			if r := recover(); r != errCrash {
+				t.Fatalf("export wasn't interrupted: %v", r)
+			}
+		}()
+		run.exportSpace("TEAM", NewProgressTracker(1))
+	}()
+
+	checkpoint, err := state.LoadCheckpoint(checkpointPath)
+	if err != nil || checkpoint == nil {
+		t.Fatalf("LoadCheckpoint = %v, %v", checkpoint, err)
+	}
+	sc := checkpoint.Scope(state.SpaceScope("TEAM"))
+	if sc.Done || sc.Offset != 30 || len(sc.Pages) != 55 {
+		t.Fatalf("checkpoint has done %v, offset %d and %d pages, want false, 30 and 55", sc.Done, sc.Offset, len(sc.Pages))
+	}
+	if before := countPages(t, textPath); before["Page 58"] != 1 {
+		t.Fatalf("page 58 written before the crash %d times, want 1", before["Page 58"])
+	}
+
+	// Resume like main does with --resume
+	handler, err = output.NewHandler(cfg.Export)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	if err := handler.(output.Checkpointer).Resume(checkpoint.Output); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	info, err := os.Stat(textPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if marker := fmt.Sprint(info.Size()); marker != checkpoint.Output {
+		t.Errorf("output file has %s bytes after resuming, want it truncated to the marker %s", marker, checkpoint.Output)
+	}
+
+	run = newTestRun(cfg, source, handler, checkpoint)
+	if err := run.exportSpace("TEAM", NewProgressTracker(1)); err != nil {
+		t.Fatalf("exportSpace: %v", err)
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	written := countPages(t, textPath)
+	for _, page := range source.pages {
+		if written[page.Title] != 1 {
+			t.Errorf("%s written %d times, want once", page.Title, written[page.Title])
+		}
+	}
+	if len(written) != len(source.pages) {
+		t.Errorf("wrote %d pages, want %d", len(written), len(source.pages))
+	}
+	next, _ := run.tracker.Finish()
+	if len(next.Pages) != len(source.pages) {
+		t.Errorf("the next state has %d pages, want %d", len(next.Pages), len(source.pages))
+	}
+}
+
+// countPages counts how often each page title appears in a single text file
+func countPages(t *testing.T, path string) map[string]int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for _, line := range strings.Split(string(data), "\n") {
+		if title, ok := strings.CutPrefix(line, "Title: "); ok {
+			counts[title]++
+		}
+	}
+	return counts
+}
+
+func TestWithoutPages(t *testing.T) {
+	pages := newFakeSource("TEAM", 4, 4).pages
+
+	remaining := withoutPages(pages, map[string]bool{"2": true, "4": true})
+	var ids []string
+	for _, page := range remaining {
+		ids = append(ids, page.ID)
+	}
+	if strings.Join(ids, ",") != "1,3" {
+		t.Errorf("withoutPages kept %v, want 1,3", ids)
+	}
+	if got := withoutPages(pages, nil); len(got) != 4 {
+		t.Errorf("withoutPages without exclusions kept %d pages, want 4", len(got))
+	}
+}
+
+func TestResumeRetriesFailedPages(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Export: config.ExportConfig{OutputType: "singletxt", OutputDir: dir, ConcurrentRequests: 1}}
+	source := newFakeSource("TEAM", 6, 3)
+
+	// The interrupted run completed the first batch, except for page 2
+	checkpoint := state.NewCheckpoint(filepath.Join(dir, state.CheckpointFileName))
+	sc := checkpoint.Scope(state.SpaceScope("TEAM"))
+	sc.Offset = 3
+	for _, page := range source.pages[:3] {
+		entry := state.NewPageState(state.SpaceScope("TEAM"), page)
+		if page.ID == "2" {
+			sc.Failed = append(sc.Failed, entry)
+		} else {
+			sc.Pages = append(sc.Pages, entry)
+		}
+	}
+
+	handler, err := output.NewHandler(cfg.Export)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	if err := handler.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	run := newTestRun(cfg, source, handler, checkpoint)
+	if err := run.exportSpace("TEAM", NewProgressTracker(1)); err != nil {
+		t.Fatalf("exportSpace: %v", err)
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	written := countPages(t, filepath.Join(dir, output.SingleTextFile))
+	want := map[string]int{"Page 2": 1, "Page 4": 1, "Page 5": 1, "Page 6": 1}
+	if fmt.Sprint(written) != fmt.Sprint(want) {
+		t.Errorf("wrote %v, want %v", written, want)
+	}
+	if len(sc.Failed) != 0 || len(sc.Pages) != 6 {
+		t.Errorf("checkpoint has %d failed and %d done pages, want 0 and 6", len(sc.Failed), len(sc.Pages))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"confluence-exporter/internal/db"
+	"confluence-exporter/internal/output"
+)
+
+// runSearch runs the search command, a full-text search over the pages
+// exported by the db output
+func runSearch(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	dbPath := flags.String("db", output.DBFile, "Path to the DuckDB database written by the db output")
+	limit := flags.Int("limit", 10, "Maximum number of results")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s search [flags] \"query\"\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	query := strings.Join(flags.Args(), " ")
+	if query == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		log.Fatalf("No database to search: %v", err)
+	}
+	conn, err := db.OpenDB(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.CloseDB(conn)
+
+	results, err := db.Search(conn, query, *limit)
+	if err != nil {
+		log.Fatalf("Search failed: %v", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("🔎 No pages match %q\n", query)
+		return
+	}
+	fmt.Printf("🔎 %d pages match %q:\n\n", len(results), query)
+	for i, r := range results {
+		fmt.Printf("%2d. %s", i+1, r.Title)
+		if r.SpaceKey != "" {
+			fmt.Printf(" (space %s)", r.SpaceKey)
+		}
+		fmt.Printf(" | score %.2f\n", r.Score)
+		if r.Link != "" {
+			fmt.Printf("    %s\n", r.Link)
+		}
+	}
+}
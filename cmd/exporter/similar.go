@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/db"
+	"confluence-exporter/internal/embed"
+	"confluence-exporter/internal/output"
+)
+
+// snippetLength is the number of characters of a chunk shown by similar
+const snippetLength = 160
+
+// runSimilar runs the similar command, a semantic search over the chunks
+// embedded by the chunks output
+func runSimilar(args []string) {
+	flags := flag.NewFlagSet("similar", flag.ExitOnError)
+	configPath := flags.String("config", "config.json", "Path to the configuration file with the embedding settings of the export")
+	dbPath := flags.String("db", output.DBFile, "Path to the DuckDB database written by the chunks output")
+	limit := flags.Int("limit", 10, "Maximum number of results")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s similar [flags] \"text\"\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	text := strings.Join(flags.Args(), " ")
+	if text == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// The query must be embedded like the chunks
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	embedder, err := embed.New(cfg.Export.Chunks.Embeddings)
+	if err != nil {
+		log.Fatalf("Failed to create embedder: %v", err)
+	}
+	if embedder == nil {
+		log.Fatalf("No embedding provider is configured in %s (export.chunks.embeddings.provider)", *configPath)
+	}
+	vectors, err := embedder.Embed([]string{text})
+	if err != nil {
+		log.Fatalf("Failed to embed the query: %v", err)
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		log.Fatalf("No database to search: %v", err)
+	}
+	conn, err := db.OpenDB(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.CloseDB(conn)
+
+	results, err := db.SimilarChunks(conn, vectors[0], embedder.Model(), *limit)
+	if err != nil {
+		log.Fatalf("Similarity search failed: %v", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("🧭 No chunks found for %q\n", text)
+		return
+	}
+	fmt.Printf("🧭 %d chunks similar to %q:\n\n", len(results), text)
+	for i, r := range results {
+		fmt.Printf("%2d. %s", i+1, strings.Join(append([]string{r.Title}, r.Headings...), " › "))
+		fmt.Printf(" | similarity %.2f\n", r.Similarity)
+		if r.Link != "" {
+			fmt.Printf("    %s\n", r.Link)
+		}
+		fmt.Printf("    %s\n", snippet(r.Text))
+	}
+}
+
+// snippet shortens text to a single line of at most snippetLength characters
+func snippet(text string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= snippetLength {
+		return string(runes)
+	}
+	return string(runes[:snippetLength]) + "…"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"confluence-exporter/internal/api"
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+	"confluence-exporter/internal/spaceexport"
+)
+
+// PageSource provides the pages to export: the REST API of a Confluence
+// instance or a space export ZIP
+type PageSource interface {
+	GetSpaces() ([]models.Space, error)
+	GetPage(pageID string) (*models.Page, error)
+	GetChildPages(parentPageID string) ([]models.Page, error)
+	ListPages(spaceKey string, start int, fn func(pages []models.Page, next int) error) error
+	ListPageSummaries(spaceKey string) ([]models.Page, error)
+	SearchPages(cql string, start int, fn func(pages []models.Page, next int) error) error
+	SearchPageSummaries(cql string) ([]models.Page, error)
+	GetPageVersions(pageID string) ([]models.PageVersion, error)
+	GetPageVersion(pageID string, number int) (*models.PageVersion, error)
+	GetAttachments(pageID string) ([]models.Attachment, error)
+	OpenAttachment(attachment models.Attachment) (io.ReadCloser, error)
+	GetBaseURL() string
+}
+
+var (
+	_ PageSource = (*api.ConfluenceClient)(nil)
+	_ PageSource = (*spaceexport.Source)(nil)
+)
+
+// newPageSource opens the space export if one is configured and creates a
+// client for the REST API otherwise
+func newPageSource(cfg config.ConfluenceConfig) (PageSource, error) {
+	if cfg.SpaceExport != "" {
+		source, err := spaceexport.Open(cfg.SpaceExport, cfg.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+
+	client := api.NewConfluenceClient(cfg.BaseURL, cfg.Username, cfg.APIToken)
+	client.Retry = api.RetryPolicy{
+		MaxRetries:     *cfg.Retry.MaxRetries,
+		InitialBackoff: time.Duration(cfg.Retry.InitialDelayMs) * time.Millisecond,
+		MaxBackoff:     time.Duration(cfg.Retry.MaxDelayMs) * time.Millisecond,
+	}
+	if cfg.RateLimit.RequestsPerSecond > 0 {
+		client.Limiter = api.NewRateLimiter(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
+	}
+	return client, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import "sync"
+
+// runOrdered calls work for every item on up to workers goroutines and hands
+// the results to emit in input order. emit always runs on the calling
+// goroutine, so it may use state that is not safe for concurrent use (like an
+// output handler). At most 2*workers results are buffered at any time.
+func runOrdered[T, R any](items []T, workers int, work func(T) R, emit func(T, R)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]chan R, len(items))
+	for i := range results {
+		results[i] = make(chan R, 1)
+	}
+
+	jobs := make(chan int)
+	window := make(chan struct{}, 2*workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] <- work(items[i])
+			}
+		}()
+	}
+
+	go func() {
+		for i := range items {
+			window <- struct{}{}
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	for i, item := range items {
+		result := <-results[i]
+		<-window
+		emit(item, result)
+	}
+
+	wg.Wait()
+}
+
+// semaphore bounds the number of concurrent API calls
+type semaphore chan struct{}
+
+func newSemaphore(n int) semaphore {
+	if n < 1 {
+		n = 1
+	}
+	return make(semaphore, n)
+}
+
+func (s semaphore) acquire() { s <- struct{}{} }
+func (s semaphore) release() { <-s }
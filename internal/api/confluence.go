@@ -1,14 +1,15 @@
 package api
 
 import (
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"confluence-exporter/internal/models"
@@ -20,6 +21,9 @@ type ConfluenceClient struct {
 	Username   string
 	APIToken   string
 	HTTPClient *http.Client
+	Retry      RetryPolicy
+	// Limiter throttles all requests of the client; nil means unlimited
+	Limiter *RateLimiter
 }
 
 // NewConfluenceClient creates a new client for interacting with Confluence
@@ -31,120 +35,178 @@ func NewConfluenceClient(baseURL, username, apiToken string) *ConfluenceClient {
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		Retry: DefaultRetryPolicy,
 	}
 }
 
 // GetSpaces retrieves all spaces the user has access to
 func (c *ConfluenceClient) GetSpaces() ([]models.Space, error) {
-	endpoint := "/rest/api/space"
-
 	var allSpaces []models.Space
-	start := 0
-	limit := 25
+	err := paginate(c, "/rest/api/space", url.Values{}, 0, 25, func(spaces []models.Space, next int) error {
+		allSpaces = append(allSpaces, spaces...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		params := url.Values{}
-		params.Add("start", strconv.Itoa(start))
-		params.Add("limit", strconv.Itoa(limit))
+	return allSpaces, nil
+}
 
-		resp, err := c.sendRequest("GET", endpoint, params, nil)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
+// GetPages retrieves all pages in a space
+func (c *ConfluenceClient) GetPages(spaceKey string) ([]models.Page, error) {
+	var allPages []models.Page
+	err := c.ListPages(spaceKey, 0, func(pages []models.Page, next int) error {
+		allPages = append(allPages, pages...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		var result struct {
-			Results []models.Space `json:"results"`
-			Size    int            `json:"size"`
-			Limit   int            `json:"limit"`
-		}
+	return allPages, nil
+}
 
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, err
-		}
+// ListPages retrieves the pages of a space batch by batch, starting at the
+// given pagination offset. fn is called for every batch with the offset of
+// the next batch; an error returned by fn stops the listing.
+func (c *ConfluenceClient) ListPages(spaceKey string, start int, fn func(pages []models.Page, next int) error) error {
+	params := url.Values{}
+	params.Add("spaceKey", spaceKey)
+	params.Add("type", "page")
+	params.Add("expand", pageExpand)
 
-		allSpaces = append(allSpaces, result.Results...)
+	return c.listContent("/rest/api/content", params, start, fn)
+}
 
-		if len(result.Results) < limit {
-			break
-		}
+// ListPageSummaries retrieves the ID, title, space and parent of all pages in a
+// space, without their content
+func (c *ConfluenceClient) ListPageSummaries(spaceKey string) ([]models.Page, error) {
+	params := url.Values{}
+	params.Add("spaceKey", spaceKey)
+	params.Add("type", "page")
+	params.Add("expand", summaryExpand)
+
+	var allPages []models.Page
+	err := c.listContent("/rest/api/content", params, 0, func(pages []models.Page, next int) error {
+		allPages = append(allPages, pages...)
+		return nil
+	})
+	return allPages, err
+}
 
-		start += limit
+// Search retrieves all pages matching a CQL query
+func (c *ConfluenceClient) Search(cql string) ([]models.Page, error) {
+	var allPages []models.Page
+	err := c.SearchPages(cql, 0, func(pages []models.Page, next int) error {
+		allPages = append(allPages, pages...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return allSpaces, nil
+	return allPages, nil
 }
 
-// GetPages retrieves all pages in a space
-func (c *ConfluenceClient) GetPages(spaceKey string) ([]models.Page, error) {
-	endpoint := "/rest/api/content"
+// SearchPages retrieves the pages matching a CQL query batch by batch,
+// starting at the given result offset. Results that are not pages (blog
+// posts, attachments, comments) are skipped. fn is called for every batch
+// with the offset of the next batch; an error returned by fn stops the search.
+func (c *ConfluenceClient) SearchPages(cql string, start int, fn func(pages []models.Page, next int) error) error {
+	params := url.Values{}
+	params.Add("cql", cql)
+	params.Add("expand", pageExpand)
+
+	return c.listContent("/rest/api/content/search", params, start, fn)
+}
+
+// SearchPageSummaries retrieves the ID, title, space and parent of all pages
+// matching a CQL query, without their content
+func (c *ConfluenceClient) SearchPageSummaries(cql string) ([]models.Page, error) {
+	params := url.Values{}
+	params.Add("cql", cql)
+	params.Add("expand", summaryExpand)
 
 	var allPages []models.Page
-	start := 0
-	limit := 25
+	err := c.listContent("/rest/api/content/search", params, 0, func(pages []models.Page, next int) error {
+		allPages = append(allPages, pages...)
+		return nil
+	})
+	return allPages, err
+}
 
-	for {
-		params := url.Values{}
-		params.Add("spaceKey", spaceKey)
-		params.Add("type", "page")
-		params.Add("expand", "body.storage,version,space")
-		params.Add("start", strconv.Itoa(start))
-		params.Add("limit", strconv.Itoa(limit))
+// listContent pages through a content listing endpoint starting at the given
+// offset and calls fn for every batch of pages
+func (c *ConfluenceClient) listContent(endpoint string, params url.Values, start int, fn func(pages []models.Page, next int) error) error {
+	return paginate(c, endpoint, params, start, 25, func(results []apiContent, next int) error {
+		var pages []models.Page
+		for _, p := range results {
+			if p.Type != "" && p.Type != "page" {
+				continue
+			}
+			pages = append(pages, c.toPage(p))
+		}
+		return fn(pages, next)
+	})
+}
+
+// paginate pages through a listing endpoint starting at the given offset and
+// calls fn for every batch of results with the offset of the next batch; an
+// error returned by fn stops the listing
+func paginate[T any](c *ConfluenceClient, endpoint string, params url.Values, start, limit int, fn func(results []T, next int) error) error {
+	params.Set("start", strconv.Itoa(start))
+	params.Set("limit", strconv.Itoa(limit))
 
+	// previous is the last next link followed, to stop if the server repeats it
+	previous := ""
+	for {
 		resp, err := c.sendRequest("GET", endpoint, params, nil)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		defer resp.Body.Close()
 
 		var result struct {
-			Results []struct {
-				ID    string `json:"id"`
-				Title string `json:"title"`
-				Type  string `json:"type"`
-				Space struct {
-					Key string `json:"key"`
-				} `json:"space"`
-				Body struct {
-					Storage struct {
-						Value string `json:"value"`
-					} `json:"storage"`
-				} `json:"body"`
-				Version struct {
-					Number int `json:"number"`
-				} `json:"version"`
-				Links struct {
-					WebUI string `json:"webui"`
-				} `json:"_links"`
-			} `json:"results"`
-			Size  int `json:"size"`
-			Limit int `json:"limit"`
+			Results []T `json:"results"`
+			Links   struct {
+				Next string `json:"next"`
+			} `json:"_links"`
 		}
 
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, err
+		// Close the body right away, fn may run for a long time
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return err
 		}
 
-		for _, p := range result.Results {
-			page := models.Page{
-				ID:       p.ID,
-				Title:    p.Title,
-				SpaceKey: p.Space.Key,
-				Version:  p.Version.Number,
-				Content:  p.Body.Storage.Value,
-				URL:      p.Links.WebUI,
+		start += len(result.Results)
+		if err := fn(result.Results, start); err != nil {
+			return err
+		}
+
+		// Follow the next link whenever there is one: Confluence Cloud may
+		// return fewer results than the limit before the last batch, and
+		// paginates search results with a cursor. Without a link, a short
+		// batch is the last one.
+		if result.Links.Next != "" {
+			if result.Links.Next == previous {
+				return fmt.Errorf("next link %q points to the same batch again", result.Links.Next)
 			}
-			allPages = append(allPages, page)
+			previous = result.Links.Next
+			next, err := url.Parse(result.Links.Next)
+			if err != nil {
+				return fmt.Errorf("invalid next link %q: %v", result.Links.Next, err)
+			}
+			params = next.Query()
+			continue
 		}
 
 		if len(result.Results) < limit {
-			break
+			return nil
 		}
-
-		start += limit
+		params.Set("start", strconv.Itoa(start))
 	}
-
-	return allPages, nil
 }
 
 // GetPage retrieves a single page by its ID
@@ -152,7 +214,7 @@ func (c *ConfluenceClient) GetPage(pageID string) (*models.Page, error) {
 	endpoint := fmt.Sprintf("/rest/api/content/%s", pageID)
 
 	params := url.Values{}
-	params.Add("expand", "body.storage,version,space,ancestors")
+	params.Add("expand", pageExpand)
 
 	resp, err := c.sendRequest("GET", endpoint, params, nil)
 	if err != nil {
@@ -160,119 +222,113 @@ func (c *ConfluenceClient) GetPage(pageID string) (*models.Page, error) {
 	}
 	defer resp.Body.Close()
 
-	var result struct {
-		ID    string `json:"id"`
-		Title string `json:"title"`
-		Space struct {
-			Key string `json:"key"`
-		} `json:"space"`
-		Body struct {
-			Storage struct {
-				Value string `json:"value"`
-			} `json:"storage"`
-		} `json:"body"`
-		Version struct {
-			Number int `json:"number"`
-		} `json:"version"`
-		Links struct {
-			WebUI string `json:"webui"`
-		} `json:"_links"`
-		Ancestors []struct {
-			ID string `json:"id"`
-		} `json:"ancestors"`
-	}
-
+	var result apiContent
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
-	page := &models.Page{
-		ID:       result.ID,
-		Title:    result.Title,
-		SpaceKey: result.Space.Key,
-		Version:  result.Version.Number,
-		Content:  result.Body.Storage.Value,
-		URL:      result.Links.WebUI,
+	page := c.toPage(result)
+	return &page, nil
+}
+
+// GetPageVersions retrieves the version history of a page, newest first. The
+// versions include the current one but not their content, which is fetched
+// with GetPageVersion.
+func (c *ConfluenceClient) GetPageVersions(pageID string) ([]models.PageVersion, error) {
+	var versions []models.PageVersion
+	list := func(endpoint string) error {
+		return paginate(c, endpoint, url.Values{}, 0, 50, func(results []apiVersion, next int) error {
+			for _, v := range results {
+				versions = append(versions, models.PageVersion{
+					Number:    v.Number,
+					CreatedAt: v.When,
+					CreatedBy: v.By.name(),
+					Message:   v.Message,
+				})
+			}
+			return nil
+		})
 	}
 
-	if len(result.Ancestors) > 0 {
-		page.ParentID = result.Ancestors[len(result.Ancestors)-1].ID
+	err := list(fmt.Sprintf("/rest/api/content/%s/version", pageID))
+	// Server and Data Center only list versions in the experimental API
+	if errors.Is(err, ErrNotFound) && len(versions) == 0 {
+		err = list(fmt.Sprintf("/rest/experimental/content/%s/version", pageID))
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return page, nil
+	return versions, nil
 }
 
-// GetChildPages retrieves all direct child pages for a given parent page ID
-func (c *ConfluenceClient) GetChildPages(parentPageID string) ([]models.Page, error) {
-	endpoint := fmt.Sprintf("/rest/api/content/%s/child/page", parentPageID)
+// GetPageVersion retrieves a historical version of a page with its title and
+// storage content
+func (c *ConfluenceClient) GetPageVersion(pageID string, number int) (*models.PageVersion, error) {
+	endpoint := fmt.Sprintf("/rest/api/content/%s", pageID)
 
-	var allPages []models.Page
-	start := 0
-	limit := 25
+	params := url.Values{}
+	params.Add("status", "historical")
+	params.Add("version", strconv.Itoa(number))
+	params.Add("expand", "body.storage,version")
 
-	for {
-		params := url.Values{}
-		params.Add("expand", "body.storage,version,space")
-		params.Add("start", strconv.Itoa(start))
-		params.Add("limit", strconv.Itoa(limit))
+	resp, err := c.sendRequest("GET", endpoint, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-		resp, err := c.sendRequest("GET", endpoint, params, nil)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
+	var result apiContent
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
 
-		var result struct {
-			Results []struct {
-				ID    string `json:"id"`
-				Title string `json:"title"`
-				Type  string `json:"type"`
-				Space struct {
-					Key string `json:"key"`
-				} `json:"space"`
-				Body struct {
-					Storage struct {
-						Value string `json:"value"`
-					} `json:"storage"`
-				} `json:"body"`
-				Version struct {
-					Number int `json:"number"`
-				} `json:"version"`
-				Links struct {
-					WebUI string `json:"webui"`
-				} `json:"_links"`
-			} `json:"results"`
-			Size  int `json:"size"`
-			Limit int `json:"limit"`
-		}
+	return &models.PageVersion{
+		Number:    result.Version.Number,
+		Title:     result.Title,
+		Content:   result.Body.Storage.Value,
+		CreatedAt: result.Version.When,
+		CreatedBy: result.Version.By.name(),
+		Message:   result.Version.Message,
+	}, nil
+}
 
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, err
-		}
+// GetChildPages retrieves all direct child pages for a given parent page ID
+func (c *ConfluenceClient) GetChildPages(parentPageID string) ([]models.Page, error) {
+	endpoint := fmt.Sprintf("/rest/api/content/%s/child/page", parentPageID)
 
-		for _, p := range result.Results {
-			page := models.Page{
-				ID:       p.ID,
-				Title:    p.Title,
-				SpaceKey: p.Space.Key,
-				Version:  p.Version.Number,
-				Content:  p.Body.Storage.Value,
-				URL:      p.Links.WebUI,
-				ParentID: parentPageID,
-			}
-			allPages = append(allPages, page)
-		}
+	params := url.Values{}
+	params.Add("expand", pageExpand)
 
-		if len(result.Results) < limit {
-			break
+	var allPages []models.Page
+	err := paginate(c, endpoint, params, 0, 25, func(results []apiContent, next int) error {
+		for _, p := range results {
+			page := c.toPage(p)
+			page.ParentID = parentPageID
+			allPages = append(allPages, page)
 		}
-
-		start += limit
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return allPages, nil
 }
 
+// apiAttachment is an attachment as returned by the attachment listing
+type apiAttachment struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Metadata struct {
+		MediaType string `json:"mediaType"`
+		Size      int64  `json:"size"`
+	} `json:"metadata"`
+	Links struct {
+		Download string `json:"download"`
+	} `json:"_links"`
+}
+
 // GetAttachments retrieves all attachments for a page
 func (c *ConfluenceClient) GetAttachments(pageID string) ([]models.Attachment, error) {
 	endpoint := fmt.Sprintf("/rest/api/content/%s/child/attachment", pageID)
@@ -280,41 +336,23 @@ func (c *ConfluenceClient) GetAttachments(pageID string) ([]models.Attachment, e
 	params := url.Values{}
 	params.Add("expand", "version")
 
-	resp, err := c.sendRequest("GET", endpoint, params, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Results []struct {
-			ID       string `json:"id"`
-			Title    string `json:"title"`
-			Metadata struct {
-				MediaType string `json:"mediaType"`
-				Size      int64  `json:"size"`
-			} `json:"metadata"`
-			Links struct {
-				Download string `json:"download"`
-			} `json:"_links"`
-		} `json:"results"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
 	var attachments []models.Attachment
-	for _, a := range result.Results {
-		attachment := models.Attachment{
-			ID:          a.ID,
-			Title:       a.Title,
-			FileName:    a.Title,
-			MediaType:   a.Metadata.MediaType,
-			FileSize:    a.Metadata.Size,
-			DownloadURL: a.Links.Download,
+	err := paginate(c, endpoint, params, 0, 50, func(results []apiAttachment, next int) error {
+		for _, a := range results {
+			attachment := models.Attachment{
+				ID:          a.ID,
+				Title:       a.Title,
+				FileName:    a.Title,
+				MediaType:   a.Metadata.MediaType,
+				FileSize:    a.Metadata.Size,
+				DownloadURL: a.Links.Download,
+			}
+			attachments = append(attachments, attachment)
 		}
-		attachments = append(attachments, attachment)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return attachments, nil
@@ -337,33 +375,31 @@ func (c *ConfluenceClient) sendRequest(method, endpoint string, params url.Value
 		apiURL.RawQuery = params.Encode()
 	}
 
-	// Create request
-	req, err := http.NewRequest(method, apiURL.String(), body)
-	if err != nil {
-		return nil, err
-	}
-
-	// Add basic auth header
-	auth := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.APIToken))
-	req.Header.Add("Authorization", "Basic "+auth)
-	req.Header.Add("Content-Type", "application/json")
-
-	// Send the request
-	return c.HTTPClient.Do(req)
+	// Send the request, retrying transient failures
+	return c.do(method, apiURL.String(), body)
 }
 
 // GetAttachmentContent downloads the content of an attachment
 func (c *ConfluenceClient) GetAttachmentContent(downloadURL string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", downloadURL, nil)
+	return c.do("GET", downloadURL, nil)
+}
+
+// OpenAttachment downloads the content of an attachment, the caller must
+// close it
+func (c *ConfluenceClient) OpenAttachment(attachment models.Attachment) (io.ReadCloser, error) {
+	resp, err := c.GetAttachmentContent(c.BaseURL + attachment.DownloadURL)
 	if err != nil {
 		return nil, err
 	}
+	return resp.Body, nil
+}
 
-	// Add basic auth header
-	auth := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.APIToken))
-	req.Header.Add("Authorization", "Basic "+auth)
-
-	return c.HTTPClient.Do(req)
+// webURL turns a relative web UI link returned by the API into an absolute URL
+func (c *ConfluenceClient) webURL(webui string) string {
+	if webui == "" || strings.HasPrefix(webui, "http://") || strings.HasPrefix(webui, "https://") {
+		return webui
+	}
+	return strings.TrimSuffix(c.BaseURL, "/") + webui
 }
 
 // GetBaseURL returns the base URL of the Confluence instance
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient creates a client for a Confluence stand-in that retries
+// without waiting
+func newTestClient(t *testing.T, handler http.HandlerFunc) *ConfluenceClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewConfluenceClient(server.URL, "user", "token")
+	client.Retry = RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return client
+}
+
+// writeBatch answers a listing request with at most size items from its start
+// parameter on, and a next link unless it is the last batch. Like Confluence
+// Cloud, it returns short batches before the last one.
+func writeBatch(w http.ResponseWriter, r *http.Request, items []map[string]any, size int) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+	end := min(start+size, len(items))
+	result := map[string]any{"results": items[start:end]}
+	if end < len(items) {
+		query := r.URL.Query()
+		query.Set("start", strconv.Itoa(end))
+		result["_links"] = map[string]string{"next": r.URL.Path + "?" + query.Encode()}
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// items creates n listing results with the given field set to 1 to n
+func items(n int, field string, number bool) []map[string]any {
+	var results []map[string]any
+	for i := 1; i <= n; i++ {
+		item := map[string]any{field: strconv.Itoa(i), "type": "page"}
+		if number {
+			item[field] = i
+		}
+		results = append(results, item)
+	}
+	return results
+}
+
+func TestListingsFollowNextLinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		items []map[string]any
+		list  func(c *ConfluenceClient) ([]string, error)
+	}{
+		{
+			name:  "spaces",
+			path:  "/rest/api/space",
+			items: items(5, "key", false),
+			list: func(c *ConfluenceClient) ([]string, error) {
+				spaces, err := c.GetSpaces()
+				var keys []string
+				for _, space := range spaces {
+					keys = append(keys, space.Key)
+				}
+				return keys, err
+			},
+		},
+		{
+			name:  "pages",
+			path:  "/rest/api/content",
+			items: items(5, "id", false),
+			list: func(c *ConfluenceClient) ([]string, error) {
+				pages, err := c.GetPages("TEAM")
+				var ids []string
+				for _, page := range pages {
+					ids = append(ids, page.ID)
+				}
+				return ids, err
+			},
+		},
+		{
+			name:  "child pages",
+			path:  "/rest/api/content/9/child/page",
+			items: items(5, "id", false),
+			list: func(c *ConfluenceClient) ([]string, error) {
+				pages, err := c.GetChildPages("9")
+				var ids []string
+				for _, page := range pages {
+					if page.ParentID != "9" {
+						return nil, fmt.Errorf("page %s has parent %q", page.ID, page.ParentID)
+					}
+					ids = append(ids, page.ID)
+				}
+				return ids, err
+			},
+		},
+		{
+			name:  "attachments",
+			path:  "/rest/api/content/9/child/attachment",
+			items: items(5, "id", false),
+			list: func(c *ConfluenceClient) ([]string, error) {
+				attachments, err := c.GetAttachments("9")
+				var ids []string
+				for _, attachment := range attachments {
+					ids = append(ids, attachment.ID)
+				}
+				return ids, err
+			},
+		},
+		{
+			name:  "versions",
+			path:  "/rest/api/content/9/version",
+			items: items(5, "number", true),
+			list: func(c *ConfluenceClient) ([]string, error) {
+				versions, err := c.GetPageVersions("9")
+				var numbers []string
+				for _, version := range versions {
+					numbers = append(numbers, strconv.Itoa(version.Number))
+				}
+				return numbers, err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					http.NotFound(w, r)
+					return
+				}
+				requests++
+				writeBatch(w, r, tt.items, 2)
+			})
+
+			got, err := tt.list(client)
+			if err != nil {
+				t.Fatalf("listing failed: %v", err)
+			}
+			if strings.Join(got, ",") != "1,2,3,4,5" {
+				t.Errorf("listed %v, want 1 to 5", got)
+			}
+			if requests != 3 {
+				t.Errorf("sent %d requests, want 3", requests)
+			}
+		})
+	}
+}
+
+func TestListingWithoutNextLinkStopsAtShortBatch(t *testing.T) {
+	var starts []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		starts = append(starts, r.URL.Query().Get("start"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		all := items(30, "key", false)
+		json.NewEncoder(w).Encode(map[string]any{"results": all[start:min(start+25, len(all))]})
+	})
+
+	spaces, err := client.GetSpaces()
+	if err != nil {
+		t.Fatalf("GetSpaces: %v", err)
+	}
+	if len(spaces) != 30 || strings.Join(starts, ",") != "0,25" {
+		t.Errorf("listed %d spaces from offsets %v, want 30 from 0,25", len(spaces), starts)
+	}
+}
+
+func TestListingStopsAtRepeatedNextLink(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"results":[{"id":"1"}],"_links":{"next":"/rest/api/content/9/child/page?start=1"}}`)
+	})
+
+	_, err := client.GetChildPages("9")
+	if err == nil || !strings.Contains(err.Error(), "points to the same batch again") {
+		t.Errorf("GetChildPages error = %v", err)
+	}
+}
+
+func TestGetPageVersionsFallsBackToExperimentalAPI(t *testing.T) {
+	var paths []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path != "/rest/experimental/content/9/version" {
+			http.NotFound(w, r)
+			return
+		}
+		writeBatch(w, r, items(3, "number", true), 2)
+	})
+
+	versions, err := client.GetPageVersions("9")
+	if err != nil {
+		t.Fatalf("GetPageVersions: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Errorf("got %d versions, want 3", len(versions))
+	}
+	if len(paths) != 3 || paths[0] != "/rest/api/content/9/version" {
+		t.Errorf("requested %v, want the REST API once before the experimental API", paths)
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import "confluence-exporter/internal/models"
+
+// pageExpand is the list of expansions requested for every page, so the
+// content, version, authorship, timestamps, labels and hierarchy are
+// available without further requests
+const pageExpand = "body.storage,version,space,history,history.lastUpdated,metadata.labels,ancestors"
+
+// summaryExpand is the list of expansions for listings that only need to
+// identify pages and their place in the hierarchy
+const summaryExpand = "space,ancestors"
+
+// apiUser is a Confluence user as returned in history and version objects
+type apiUser struct {
+	DisplayName string `json:"displayName"`
+	PublicName  string `json:"publicName"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+}
+
+// name returns the most readable identifier of the user
+func (u apiUser) name() string {
+	for _, name := range []string{u.DisplayName, u.PublicName, u.Username, u.Email} {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// apiVersion is a page version as returned in content and version listings
+type apiVersion struct {
+	Number  int     `json:"number"`
+	When    string  `json:"when"`
+	Message string  `json:"message"`
+	By      apiUser `json:"by"`
+}
+
+// apiContent is a page as returned by the content endpoints with pageExpand
+type apiContent struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+	Space struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	} `json:"space"`
+	Body struct {
+		Storage struct {
+			Value string `json:"value"`
+		} `json:"storage"`
+	} `json:"body"`
+	Version apiVersion `json:"version"`
+	History struct {
+		CreatedDate string  `json:"createdDate"`
+		CreatedBy   apiUser `json:"createdBy"`
+		LastUpdated struct {
+			When string  `json:"when"`
+			By   apiUser `json:"by"`
+		} `json:"lastUpdated"`
+	} `json:"history"`
+	Metadata struct {
+		Labels struct {
+			Results []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"results"`
+		} `json:"labels"`
+	} `json:"metadata"`
+	Ancestors []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"ancestors"`
+	Links struct {
+		WebUI string `json:"webui"`
+	} `json:"_links"`
+}
+
+// toPage maps the API representation to a models.Page
+func (c *ConfluenceClient) toPage(content apiContent) models.Page {
+	page := models.Page{
+		ID:             content.ID,
+		Title:          content.Title,
+		SpaceKey:       content.Space.Key,
+		SpaceName:      content.Space.Name,
+		Version:        content.Version.Number,
+		Content:        content.Body.Storage.Value,
+		URL:            c.webURL(content.Links.WebUI),
+		CreatedAt:      content.History.CreatedDate,
+		CreatedBy:      content.History.CreatedBy.name(),
+		UpdatedAt:      content.Version.When,
+		UpdatedBy:      content.Version.By.name(),
+		VersionMessage: content.Version.Message,
+	}
+
+	// Older Confluence versions only report the last update in the history
+	if page.UpdatedAt == "" {
+		page.UpdatedAt = content.History.LastUpdated.When
+	}
+	if page.UpdatedBy == "" {
+		page.UpdatedBy = content.History.LastUpdated.By.name()
+	}
+
+	for _, ancestor := range content.Ancestors {
+		page.Ancestors = append(page.Ancestors, models.Ancestor{ID: ancestor.ID, Title: ancestor.Title})
+	}
+	if len(content.Ancestors) > 0 {
+		page.ParentID = content.Ancestors[len(content.Ancestors)-1].ID
+	}
+
+	for _, label := range content.Metadata.Labels.Results {
+		page.Labels = append(page.Labels, models.Label{ID: label.ID, Name: label.Name})
+	}
+
+	return page
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// minRateFactor is the lowest fraction of the configured rate the limiter
+// slows down to when the server keeps signaling throttling
+const minRateFactor = 0.05
+
+// RateLimiter is a token bucket shared by all requests of a ConfluenceClient.
+//
+// It adapts to the server: Throttle halves the current rate, and every
+// successful request raises it again by a small step until the configured
+// rate is reached. All methods are safe for concurrent use and a nil
+// *RateLimiter never blocks.
+type RateLimiter struct {
+	mu      sync.Mutex
+	maxRate float64
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+}
+
+// NewRateLimiter creates a limiter allowing requestsPerSecond requests on
+// average and bursts of up to burst requests
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		maxRate: requestsPerSecond,
+		rate:    requestsPerSecond,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent
+func (l *RateLimiter) Wait() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	l.refill()
+	// Reserve a token; a negative balance is the debt later callers wait for
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// Throttle halves the current rate after the server signaled throttling
+func (l *RateLimiter) Throttle() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.rate = max(l.rate/2, l.maxRate*minRateFactor)
+	l.tokens = min(l.tokens, 0)
+}
+
+// Success slowly raises the rate back towards the configured rate
+func (l *RateLimiter) Success() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate < l.maxRate {
+		l.refill()
+		l.rate = min(l.rate+l.maxRate/50, l.maxRate)
+	}
+}
+
+// Rate returns the current number of requests per second
+func (l *RateLimiter) Rate() float64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// refill adds the tokens accumulated since the last call; l.mu must be held
+func (l *RateLimiter) refill() {
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = now
+}
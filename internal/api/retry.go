@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// InitialBackoff is the base delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used by clients created with NewConfluenceClient
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+}
+
+// backoff returns the jittered exponential delay before retry number attempt (0-based)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff << attempt
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Jitter spreads out retries from concurrent workers
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// do sends a request built from method, rawURL and body, retrying rate-limited
+// requests, server errors and network failures according to the client's
+// retry policy. Every attempt waits for the client's rate limiter, which is
+// slowed down whenever the server signals throttling. Non-2xx responses are
+// returned as *APIError.
+func (c *ConfluenceClient) do(method, rawURL string, body io.Reader) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		c.Limiter.Wait()
+		resp, err := c.doOnce(method, rawURL, payload)
+		if err == nil {
+			if resp.Header.Get("X-RateLimit-NearLimit") == "true" {
+				c.Limiter.Throttle()
+			} else {
+				c.Limiter.Success()
+			}
+			return resp, nil
+		}
+
+		var apiErr *APIError
+		isAPIErr := errors.As(err, &apiErr)
+		if isAPIErr && (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable) {
+			c.Limiter.Throttle()
+		}
+		if (isAPIErr && !apiErr.retryable()) || attempt >= c.Retry.MaxRetries {
+			return nil, err
+		}
+
+		delay := c.Retry.backoff(attempt)
+		if isAPIErr && apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+		log.Printf("⏳ Request failed (%v), retrying in %s (attempt %d/%d)", err, delay.Round(time.Millisecond), attempt+1, c.Retry.MaxRetries)
+		time.Sleep(delay)
+	}
+}
+
+// doOnce performs a single authenticated request
+func (c *ConfluenceClient) doOnce(method, rawURL string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add basic auth header
+	auth := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.APIToken))
+	req.Header.Add("Authorization", "Basic "+auth)
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	return nil, &APIError{
+		Method:     method,
+		URL:        rawURL,
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(message)),
+		RetryAfter: retryAfter(resp.Header),
+	}
+}
+
+// retryAfter extracts the delay requested by the server from the Retry-After
+// or X-RateLimit-Reset headers
+func retryAfter(header http.Header) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if when, err := http.ParseTime(value); err == nil {
+			return time.Until(when)
+		}
+	}
+
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		value := header.Get("X-RateLimit-Reset")
+		if when, err := time.Parse(time.RFC3339, value); err == nil {
+			return time.Until(when)
+		}
+		if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Until(time.Unix(unix, 0))
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		min     time.Duration
+		max     time.Duration
+	}{
+		{name: "seconds", headers: map[string]string{"Retry-After": "7"}, min: 7 * time.Second, max: 7 * time.Second},
+		{name: "HTTP date", headers: map[string]string{"Retry-After": time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)}, min: 8 * time.Second, max: 10 * time.Second},
+		{name: "rate limit reset as Unix time", headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(time.Now().Add(20 * time.Second).Unix())}, min: 18 * time.Second, max: 20 * time.Second},
+		{name: "rate limit reset as RFC 3339", headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": time.Now().Add(20 * time.Second).Format(time.RFC3339)}, min: 18 * time.Second, max: 20 * time.Second},
+		{name: "rate limit not reached", headers: map[string]string{"X-RateLimit-Remaining": "3", "X-RateLimit-Reset": "1700000000"}},
+		{name: "no header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				for name, value := range tt.headers {
+					w.Header().Set(name, value)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+			})
+			client.Retry.MaxRetries = 0
+
+			_, err := client.GetPage("1")
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || !errors.Is(err, ErrRateLimited) {
+				t.Fatalf("GetPage error = %v, want a rate limited *APIError", err)
+			}
+			if apiErr.RetryAfter < tt.min || apiErr.RetryAfter > tt.max {
+				t.Errorf("RetryAfter = %s, want between %s and %s", apiErr.RetryAfter, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestRetryWaitsForRetryAfter(t *testing.T) {
+	attempts := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"id":"1"}`)
+	})
+
+	started := time.Now()
+	page, err := client.GetPage("1")
+	if err != nil {
+		t.Fatalf("GetPage: %v", err)
+	}
+	if page.ID != "1" || attempts != 2 {
+		t.Errorf("got page %q after %d attempts, want page 1 after 2", page.ID, attempts)
+	}
+	// The backoff of the test client is 1ms, the server asked for a second
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Errorf("retried after %s, before the requested second", elapsed)
+	}
+}
+
+func TestRetryStatusCodes(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "service unavailable then success", statuses: []int{503, 200}, maxRetries: 2, wantAttempts: 2},
+		{name: "server errors until retries run out", statuses: []int{500, 502, 504}, maxRetries: 2, wantAttempts: 3, wantErr: ErrServerError},
+		{name: "retries disabled", statuses: []int{503, 200}, maxRetries: 0, wantAttempts: 1, wantErr: ErrServerError},
+		{name: "unauthorized", statuses: []int{401, 200}, maxRetries: 2, wantAttempts: 1, wantErr: ErrUnauthorized},
+		{name: "forbidden", statuses: []int{403, 200}, maxRetries: 2, wantAttempts: 1, wantErr: ErrForbidden},
+		{name: "not found", statuses: []int{404, 200}, maxRetries: 2, wantAttempts: 1, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[attempts]
+				attempts++
+				if status != http.StatusOK {
+					http.Error(w, "failed", status)
+					return
+				}
+				fmt.Fprint(w, `{"id":"1"}`)
+			})
+			client.Retry.MaxRetries = tt.maxRetries
+
+			_, err := client.GetPage("1")
+			if attempts != tt.wantAttempts {
+				t.Errorf("sent %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("GetPage: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetPage error = %v, want %v", err, tt.wantErr)
+			}
+			// Callers wrap the errors, as the export does per space
+			if wrapped := fmt.Errorf("space TEAM: %w", err); !errors.Is(wrapped, tt.wantErr) {
+				t.Errorf("wrapped error %v doesn't match %v", wrapped, tt.wantErr)
+			}
+			for _, other := range []error{ErrRateLimited, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrServerError} {
+				if other != tt.wantErr && errors.Is(err, other) {
+					t.Errorf("GetPage error %v also matches %v", err, other)
+				}
+			}
+			if !strings.Contains(err.Error(), "failed") {
+				t.Errorf("error %q doesn't include the response body", err)
+			}
+		})
+	}
+}
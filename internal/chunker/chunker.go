@@ -0,0 +1,222 @@
+package chunker
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Units the size of a chunk can be measured in
+const (
+	UnitTokens     = "tokens"
+	UnitCharacters = "characters"
+)
+
+// Options control how Markdown is split into chunks
+type Options struct {
+	// Unit is UnitTokens or UnitCharacters
+	Unit string
+	// Size is the maximum size of a chunk
+	Size int
+	// Overlap is how much of the end of a chunk is repeated at the start of
+	// the next chunk of the same section
+	Overlap int
+}
+
+// Chunk is a part of a Markdown document
+type Chunk struct {
+	// Headings are the headings the chunk is nested in, outermost first
+	Headings []string
+	Text     string
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	fencePattern   = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// separators are tried in order to split text that is too large, from
+// paragraphs down to words
+var separators = []string{"\n\n", "\n", " "}
+
+// section is the text between two headings
+type section struct {
+	headings []string
+	text     string
+	// body is false if the section has nothing but its heading
+	body bool
+}
+
+// Split splits Markdown at its headings into chunks of at most opts.Size.
+// Sections that are too large are split at paragraphs, lines and words, in
+// that order. Sections without content besides their heading are skipped.
+func Split(markdown string, opts Options) []Chunk {
+	var chunks []Chunk
+	for _, s := range sections(markdown) {
+		if !s.body {
+			continue
+		}
+		for _, text := range pack(split(s.text, separators, opts), opts) {
+			chunks = append(chunks, Chunk{Headings: s.headings, Text: text})
+		}
+	}
+	return chunks
+}
+
+// sections splits Markdown at ATX headings outside of code blocks
+func sections(markdown string) []section {
+	var result []section
+	var trail []string
+	var levels []int
+	current := section{}
+	var text strings.Builder
+	fence := ""
+
+	flush := func() {
+		current.text = strings.TrimSpace(text.String())
+		if current.text != "" {
+			result = append(result, current)
+		}
+		text.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if m := fencePattern.FindStringSubmatch(trimmed); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if m[1] == fence {
+				fence = ""
+			}
+		} else if m := headingPattern.FindStringSubmatch(trimmed); m != nil && fence == "" {
+			flush()
+
+			level := len(m[1])
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+				trail = trail[:len(trail)-1]
+			}
+			levels = append(levels, level)
+			trail = append(trail, m[2])
+			current = section{headings: append([]string(nil), trail...)}
+			text.WriteString(line)
+			continue
+		}
+
+		if strings.TrimSpace(line) != "" {
+			current.body = true
+		}
+		text.WriteString(line)
+	}
+	flush()
+	return result
+}
+
+// Measure returns the size of text in the unit of the options
+func (opts Options) Measure(text string) int {
+	if opts.Unit == UnitCharacters {
+		return utf8.RuneCountInString(text)
+	}
+	return EstimateTokens(text)
+}
+
+// EstimateTokens approximates the number of tokens of text for the
+// tokenizers of common language models: a token per four letters or digits
+// of a word and per punctuation character
+func EstimateTokens(text string) int {
+	tokens, word := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word++
+			continue
+		case !unicode.IsSpace(r):
+			tokens++
+		}
+		tokens += (word + 3) / 4
+		word = 0
+	}
+	return tokens + (word+3)/4
+}
+
+// split cuts text into pieces of at most opts.Size at the first separator
+// that makes them small enough. The pieces keep their separators, so joining
+// them gives text again.
+func split(text string, seps []string, opts Options) []string {
+	if opts.Measure(text) <= opts.Size {
+		return []string{text}
+	}
+	if len(seps) == 0 {
+		return cut(text, opts)
+	}
+
+	var pieces []string
+	for _, part := range strings.SplitAfter(text, seps[0]) {
+		if part != "" {
+			pieces = append(pieces, split(part, seps[1:], opts)...)
+		}
+	}
+	return pieces
+}
+
+// cut splits text without separators, like a long word or URL, into pieces of
+// at most opts.Size
+func cut(text string, opts Options) []string {
+	var pieces []string
+	for text != "" {
+		runes := []rune(text)
+		// The longest prefix that fits, at least one character
+		n := sort.Search(len(runes), func(i int) bool {
+			return opts.Measure(string(runes[:i+1])) > opts.Size
+		})
+		n = max(n, 1)
+		pieces = append(pieces, string(runes[:n]))
+		text = string(runes[n:])
+	}
+	return pieces
+}
+
+// pack joins consecutive pieces into chunks of at most opts.Size. Each chunk
+// starts with the last opts.Overlap of the previous one, if that still fits.
+func pack(pieces []string, opts Options) []string {
+	var chunks []string
+	var current strings.Builder
+
+	for _, piece := range pieces {
+		if current.Len() > 0 && opts.Measure(current.String()+piece) > opts.Size {
+			previous := current.String()
+			chunks = append(chunks, previous)
+			current.Reset()
+			if tail := overlap(previous, opts); opts.Measure(tail+piece) <= opts.Size {
+				current.WriteString(tail)
+			}
+		}
+		current.WriteString(piece)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	var result []string
+	for _, chunk := range chunks {
+		if chunk = strings.TrimSpace(chunk); chunk != "" {
+			result = append(result, chunk)
+		}
+	}
+	return result
+}
+
+// overlap returns the words at the end of text that fit into opts.Overlap
+func overlap(text string, opts Options) string {
+	if opts.Overlap <= 0 {
+		return ""
+	}
+	words := strings.SplitAfter(text, " ")
+	start := len(words)
+	for start > 0 && opts.Measure(strings.Join(words[start-1:], "")) <= opts.Overlap {
+		start--
+	}
+	return strings.Join(words[start:], "")
+}
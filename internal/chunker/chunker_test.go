@@ -0,0 +1,181 @@
+package chunker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	characters := func(size, overlap int) Options {
+		return Options{Unit: UnitCharacters, Size: size, Overlap: overlap}
+	}
+
+	tests := []struct {
+		name     string
+		markdown string
+		opts     Options
+		want     []Chunk
+	}{
+		{
+			name:     "sections keep their heading trail",
+			markdown: "# A\n\nintro\n\n## B\n\nbody b\n\n# C\n\nbody c\n",
+			opts:     characters(100, 0),
+			want: []Chunk{
+				{Headings: []string{"A"}, Text: "# A\n\nintro"},
+				{Headings: []string{"A", "B"}, Text: "## B\n\nbody b"},
+				{Headings: []string{"C"}, Text: "# C\n\nbody c"},
+			},
+		},
+		{
+			name:     "text before the first heading",
+			markdown: "intro\n# A\nx",
+			opts:     characters(100, 0),
+			want: []Chunk{
+				{Text: "intro"},
+				{Headings: []string{"A"}, Text: "# A\nx"},
+			},
+		},
+		{
+			name:     "sections with only a heading are skipped",
+			markdown: "# A\n## B ##\ntext\n### C\n",
+			opts:     characters(100, 0),
+			want:     []Chunk{{Headings: []string{"A", "B"}, Text: "## B ##\ntext"}},
+		},
+		{
+			name:     "headings in code blocks are text",
+			markdown: "# A\n```\n# not a heading\n```\n",
+			opts:     characters(100, 0),
+			want:     []Chunk{{Headings: []string{"A"}, Text: "# A\n```\n# not a heading\n```"}},
+		},
+		{
+			name:     "large sections split at paragraphs",
+			markdown: "aaaa bbbb\n\ncccc dddd",
+			opts:     characters(12, 0),
+			want:     []Chunk{{Text: "aaaa bbbb"}, {Text: "cccc dddd"}},
+		},
+		{
+			name:     "paragraphs split at words with overlap",
+			markdown: "one two three four",
+			opts:     characters(10, 4),
+			want:     []Chunk{{Text: "one two"}, {Text: "two three"}, {Text: "four"}},
+		},
+		{
+			name:     "words longer than a chunk are cut",
+			markdown: "abcdefghij",
+			opts:     characters(4, 0),
+			want:     []Chunk{{Text: "abcd"}, {Text: "efgh"}, {Text: "ij"}},
+		},
+		{
+			name:     "sizes in estimated tokens",
+			markdown: "hello world",
+			opts:     Options{Unit: UnitTokens, Size: 2},
+			want:     []Chunk{{Text: "hello"}, {Text: "world"}},
+		},
+		{
+			name:     "empty document",
+			markdown: "\n\n",
+			opts:     characters(10, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.markdown, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() = %q, want %q", got, tt.want)
+			}
+			for _, chunk := range got {
+				if size := tt.opts.Measure(chunk.Text); size > tt.opts.Size {
+					t.Errorf("chunk %q has size %d, above %d", chunk.Text, size, tt.opts.Size)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPieces(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []string
+	}{
+		{name: "fits", text: "a b\n\nc", size: 10, want: []string{"a b\n\nc"}},
+		{name: "paragraphs first", text: "a b\n\nc d", size: 5, want: []string{"a b\n\n", "c d"}},
+		{name: "then lines", text: "ab\ncd\nef", size: 4, want: []string{"ab\n", "cd\n", "ef"}},
+		{name: "then words", text: "ab cd", size: 3, want: []string{"ab ", "cd"}},
+		{name: "then characters", text: "héllo", size: 2, want: []string{"hé", "ll", "o"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := split(tt.text, separators, Options{Unit: UnitCharacters, Size: tt.size})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("split() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPack(t *testing.T) {
+	tests := []struct {
+		name    string
+		pieces  []string
+		size    int
+		overlap int
+		want    []string
+	}{
+		{name: "joins pieces that fit", pieces: []string{"a ", "b ", "c"}, size: 4, want: []string{"a b", "c"}},
+		{name: "drops blank chunks", pieces: []string{"  ", "x"}, size: 1, want: []string{"x"}},
+		{name: "repeats the end of the previous chunk", pieces: []string{"one ", "two ", "three ", "four"}, size: 10, overlap: 4, want: []string{"one two", "two three", "four"}},
+		{name: "skips an overlap that doesn't fit", pieces: []string{"aaa ", "bbbbb"}, size: 6, overlap: 4, want: []string{"aaa", "bbbbb"}},
+		{name: "nothing to pack", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pack(tt.pieces, Options{Unit: UnitCharacters, Size: tt.size, Overlap: tt.overlap})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pack() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		text    string
+		overlap int
+		want    string
+	}{
+		{text: "one two three", overlap: 0, want: ""},
+		{text: "one two three", overlap: 3, want: ""},
+		{text: "one two three", overlap: 5, want: "three"},
+		{text: "one two three", overlap: 9, want: "two three"},
+		{text: "one two three", overlap: 20, want: "one two three"},
+		{text: "one two ", overlap: 4, want: "two "},
+	}
+	for _, tt := range tests {
+		got := overlap(tt.text, Options{Unit: UnitCharacters, Overlap: tt.overlap})
+		if got != tt.want {
+			t.Errorf("overlap(%q, %d) = %q, want %q", tt.text, tt.overlap, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "word", want: 1},
+		{text: "hello", want: 2},
+		{text: "hello, world!", want: 6},
+		{text: "a b c", want: 3},
+		{text: "12345678", want: 2},
+		{text: "  \n\t", want: 0},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
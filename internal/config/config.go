@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 )
 
@@ -14,27 +15,121 @@ type Config struct {
 
 // ConfluenceConfig holds Confluence API connection settings
 type ConfluenceConfig struct {
-	BaseURL  string `json:"baseUrl"`
-	APIToken string `json:"apiToken"`
-	Username string `json:"username"`
+	BaseURL   string          `json:"baseUrl"`
+	APIToken  string          `json:"apiToken"`
+	Username  string          `json:"username"`
+	Retry     RetryConfig     `json:"retry"`
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	// SpaceExport is the path of a space export ZIP to read pages from
+	// instead of the REST API
+	SpaceExport string `json:"spaceExport"`
+}
+
+// RateLimitConfig holds settings for client-side request throttling
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// RetryConfig holds settings for retrying failed API requests
+type RetryConfig struct {
+	// MaxRetries is a pointer so that an explicit 0, which disables retries,
+	// can be told apart from a missing setting
+	MaxRetries     *int `json:"maxRetries"`
+	InitialDelayMs int  `json:"initialDelayMs"`
+	MaxDelayMs     int  `json:"maxDelayMs"`
 }
 
 // ExportConfig holds settings for the export process
 type ExportConfig struct {
-	SpaceKey           string       `json:"spaceKey"`
-	PageID             string       `json:"pageId"`
-	OutputDir          string       `json:"outputDir"`
-	OutputType         string       `json:"outputType"`
-	Recursive          bool         `json:"recursive"`
-	IncludeAttachments bool         `json:"includeAttachments"`
-	ConcurrentRequests int          `json:"concurrentRequests"`
-	Format             FormatConfig `json:"format"`
+	SpaceKey           string `json:"spaceKey"`
+	PageID             string `json:"pageId"`
+	CQL                string `json:"cql"`
+	OutputDir          string `json:"outputDir"`
+	OutputType         string `json:"outputType"`
+	Recursive          bool   `json:"recursive"`
+	IncludeAttachments bool   `json:"includeAttachments"`
+	ConcurrentRequests int    `json:"concurrentRequests"`
+	// IncludeVersions exports the historical versions of every page
+	IncludeVersions bool `json:"includeVersions"`
+	// MaxVersions limits the export to the latest historical versions (all if 0)
+	MaxVersions   int                 `json:"maxVersions"`
+	Format        FormatConfig        `json:"format"`
+	MeiliSearch   MeiliSearchConfig   `json:"meilisearch"`
+	Elasticsearch ElasticsearchConfig `json:"elasticsearch"`
+	Chunks        ChunkConfig         `json:"chunks"`
+}
+
+// ChunkConfig holds settings for the chunks output, which splits pages into
+// chunks for retrieval pipelines
+type ChunkConfig struct {
+	// Unit of Size and Overlap: "tokens" (estimated) or "characters"
+	Unit string `json:"unit"`
+	// Size is the maximum size of a chunk
+	Size int `json:"size"`
+	// Overlap is the size of the text repeated from the previous chunk
+	Overlap int `json:"overlap"`
+	// Format is "jsonl" or "duckdb"
+	Format     string          `json:"format"`
+	Embeddings EmbeddingConfig `json:"embeddings"`
+}
+
+// EmbeddingConfig holds settings for computing embedding vectors of chunks
+type EmbeddingConfig struct {
+	// Provider is "openai" or "hash", no embeddings are computed if it is empty
+	Provider string `json:"provider"`
+	// URL is the base URL of an OpenAI-compatible API
+	URL    string `json:"url"`
+	APIKey string `json:"apiKey"`
+	Model  string `json:"model"`
+	// Dimensions is the size of the vectors, if the provider supports choosing it
+	Dimensions int `json:"dimensions"`
+	// BatchSize is the number of texts embedded per request
+	BatchSize int `json:"batchSize"`
+}
+
+// MeiliSearchConfig holds settings for indexing pages in a MeiliSearch
+// instance. Without a URL, the meilisearch output writes a JSON file instead.
+type MeiliSearchConfig struct {
+	URL    string `json:"url"`
+	APIKey string `json:"apiKey"`
+	// Index is the uid of the index, created if it doesn't exist
+	Index string `json:"index"`
+	// BatchSize is the number of documents sent per request
+	BatchSize int `json:"batchSize"`
+}
+
+// ElasticsearchConfig holds settings for the Elasticsearch/OpenSearch bulk
+// output. Without a URL, the bulk file is only written to the output directory.
+type ElasticsearchConfig struct {
+	URL string `json:"url"`
+	// Username and Password are used for basic authentication
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// APIKey is an Elasticsearch API key, used instead of basic authentication
+	APIKey string `json:"apiKey"`
+	Index  string `json:"index"`
+	// BatchSize is the number of documents sent per bulk request
+	BatchSize int `json:"batchSize"`
 }
 
 // FormatConfig holds settings for markdown formatting
 type FormatConfig struct {
 	IncludeFrontMatter bool `json:"includeFrontMatter"`
-	PreserveLinks      bool `json:"preserveLinks"`
+	// FrontMatterFields selects the front matter fields to write (all if empty)
+	FrontMatterFields []string `json:"frontMatterFields"`
+	// FrontMatterFlavor renames keys for "hugo", "jekyll" or "docusaurus"
+	FrontMatterFlavor string `json:"frontMatterFlavor"`
+	PreserveLinks     bool   `json:"preserveLinks"`
+	// HTMLTableFallback writes tables with merged cells or block content as
+	// HTML tables instead of flattened Markdown tables
+	HTMLTableFallback bool `json:"htmlTableFallback"`
+	// AdmonitionFlavor selects how info, note, warning and tip panels are
+	// written: "blockquote", "github", "mkdocs" or "docusaurus"
+	AdmonitionFlavor string `json:"admonitionFlavor"`
+	// ImageFormat selects how image size and captions are written:
+	// "markdown", "attributes" or "html"
+	ImageFormat string `json:"imageFormat"`
 }
 
 // LoggingConfig holds logging settings
@@ -62,6 +157,74 @@ func LoadConfig(path string) (*Config, error) {
 	if config.Export.OutputDir == "" {
 		config.Export.OutputDir = "./output"
 	}
+	if config.Confluence.Retry.MaxRetries == nil {
+		maxRetries := 5
+		config.Confluence.Retry.MaxRetries = &maxRetries
+	}
+	if config.Confluence.Retry.InitialDelayMs <= 0 {
+		config.Confluence.Retry.InitialDelayMs = 500
+	}
+	if config.Confluence.Retry.MaxDelayMs <= 0 {
+		config.Confluence.Retry.MaxDelayMs = 30000
+	}
+	if config.Export.ConcurrentRequests <= 0 {
+		config.Export.ConcurrentRequests = 1
+	}
+	if config.Export.MeiliSearch.Index == "" {
+		config.Export.MeiliSearch.Index = "confluence"
+	}
+	if config.Export.MeiliSearch.BatchSize <= 0 {
+		config.Export.MeiliSearch.BatchSize = 500
+	}
+	if config.Export.Elasticsearch.Index == "" {
+		config.Export.Elasticsearch.Index = "confluence"
+	}
+	if config.Export.Elasticsearch.BatchSize <= 0 {
+		config.Export.Elasticsearch.BatchSize = 500
+	}
+	switch config.Export.Chunks.Unit {
+	case "":
+		config.Export.Chunks.Unit = "tokens"
+	case "tokens", "characters":
+	default:
+		return nil, fmt.Errorf("unknown chunk unit %q (available: tokens, characters)", config.Export.Chunks.Unit)
+	}
+	switch config.Export.Chunks.Format {
+	case "":
+		config.Export.Chunks.Format = "jsonl"
+	case "jsonl", "duckdb":
+	default:
+		return nil, fmt.Errorf("unknown chunk format %q (available: jsonl, duckdb)", config.Export.Chunks.Format)
+	}
+	if config.Export.Chunks.Size <= 0 {
+		config.Export.Chunks.Size = 512
+	}
+	if config.Export.Chunks.Embeddings.BatchSize <= 0 {
+		config.Export.Chunks.Embeddings.BatchSize = 64
+	}
+	if config.Export.Chunks.Overlap < 0 || config.Export.Chunks.Overlap >= config.Export.Chunks.Size {
+		return nil, fmt.Errorf("chunk overlap must be between 0 and the chunk size %d", config.Export.Chunks.Size)
+	}
+	if config.Confluence.SpaceExport != "" && config.Export.CQL != "" {
+		return nil, fmt.Errorf("cql needs the Confluence REST API and can't be used with spaceExport")
+	}
+	if config.Export.MaxVersions < 0 {
+		return nil, fmt.Errorf("maxVersions must not be negative")
+	}
+	switch config.Export.Format.AdmonitionFlavor {
+	case "":
+		config.Export.Format.AdmonitionFlavor = "blockquote"
+	case "blockquote", "github", "mkdocs", "docusaurus":
+	default:
+		return nil, fmt.Errorf("unknown admonition flavor %q (available: blockquote, github, mkdocs, docusaurus)", config.Export.Format.AdmonitionFlavor)
+	}
+	switch config.Export.Format.ImageFormat {
+	case "":
+		config.Export.Format.ImageFormat = "markdown"
+	case "markdown", "attributes", "html":
+	default:
+		return nil, fmt.Errorf("unknown image format %q (available: markdown, attributes, html)", config.Export.Format.ImageFormat)
+	}
 
 	return &config, nil
 }
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigMaxRetries(t *testing.T) {
+	tests := []struct {
+		name  string
+		retry string
+		want  int
+	}{
+		{name: "missing", retry: `{}`, want: 5},
+		{name: "disabled", retry: `{"maxRetries": 0}`, want: 0},
+		{name: "set", retry: `{"maxRetries": 2}`, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(`{"confluence": {"retry": `+tt.retry+`}}`), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if got := *cfg.Confluence.Retry.MaxRetries; got != tt.want {
+				t.Errorf("maxRetries = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package converter
+
+import (
+	"html"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ImageAttachments returns the file names of the page's own attachments that
+// are embedded as images
+func ImageAttachments(content string) ([]string, error) {
+	doc, err := parseStorage(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	doc.Find("ac\\:image ri\\:attachment").Each(func(i int, attachment *goquery.Selection) {
+		// Attachments of other pages carry a reference to their page
+		if attachment.Find("ri\\:page, ri\\:blog-post").Length() > 0 {
+			return
+		}
+		name, _ := attachment.Attr("ri:filename")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	})
+	return names, nil
+}
+
+// image is an embedded image with the attributes kept in the Markdown output
+type image struct {
+	src     string
+	alt     string
+	title   string
+	width   string
+	height  string
+	caption string
+}
+
+// processImage converts an ac:image to a Markdown image. Images that can't
+// be located are reduced to their alternative text.
+func (c *converter) processImage(node *goquery.Selection, markdown *strings.Builder) {
+	img := image{src: c.imageSource(node)}
+	img.alt, _ = node.Attr("ac:alt")
+	if img.alt == "" {
+		img.alt = trimText(node.Find("ac\\:alt").Text())
+	}
+	img.title, _ = node.Attr("ac:title")
+	img.width, _ = node.Attr("ac:width")
+	img.height, _ = node.Attr("ac:height")
+	img.caption = trimText(node.Find("ac\\:caption").Text())
+
+	if img.src == "" {
+		markdown.WriteString(escapeText(img.alt))
+		return
+	}
+
+	switch c.opts.ImageFormat {
+	case "html":
+		markdown.WriteString(img.html())
+	case "attributes":
+		markdown.WriteString(img.markdown() + img.attributes())
+	default:
+		markdown.WriteString(img.markdown())
+	}
+}
+
+// imageSource returns the link target of an ac:image
+func (c *converter) imageSource(node *goquery.Selection) string {
+	if src, ok := node.Find("ri\\:url").Attr("ri:value"); ok {
+		return src
+	}
+
+	attachment := node.Find("ri\\:attachment")
+	if attachment.Length() == 0 || attachment.Find("ri\\:page, ri\\:blog-post").Length() > 0 {
+		return ""
+	}
+	name, _ := attachment.Attr("ri:filename")
+	return escapeLinkPath(c.opts.Attachments[name])
+}
+
+// markdown returns the image in Markdown syntax, using the caption as title
+// if the image has none
+func (img image) markdown() string {
+	title := img.title
+	if title == "" {
+		title = img.caption
+	}
+	if title == "" {
+		return "![" + escapeText(img.alt) + "](" + img.src + ")"
+	}
+	return "![" + escapeText(img.alt) + "](" + img.src + " \"" + strings.ReplaceAll(title, "\"", "\\\"") + "\")"
+}
+
+// attributes returns the width and height as an attribute list
+func (img image) attributes() string {
+	var attrs []string
+	if img.width != "" {
+		attrs = append(attrs, "width=\""+img.width+"\"")
+	}
+	if img.height != "" {
+		attrs = append(attrs, "height=\""+img.height+"\"")
+	}
+	if len(attrs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(attrs, " ") + "}"
+}
+
+// html returns the image as an <img> tag in a <figure> with its caption
+func (img image) html() string {
+	tag := "<img src=\"" + html.EscapeString(img.src) + "\" alt=\"" + html.EscapeString(img.alt) + "\""
+	for _, attr := range [][2]string{{"title", img.title}, {"width", img.width}, {"height", img.height}} {
+		if attr[1] != "" {
+			tag += " " + attr[0] + "=\"" + html.EscapeString(attr[1]) + "\""
+		}
+	}
+	tag += ">"
+
+	if img.caption == "" {
+		return tag
+	}
+	return "<figure>" + tag + "<figcaption>" + html.EscapeString(img.caption) + "</figcaption></figure>"
+}
@@ -0,0 +1,176 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// whitespacePattern matches runs of whitespace, which HTML renders as one space
+var whitespacePattern = regexp.MustCompile(`\s+`)
+
+// inline renders the contents of node as inline Markdown
+func (c *converter) inline(node *goquery.Selection) string {
+	var text strings.Builder
+	node.Contents().Each(func(i int, child *goquery.Selection) {
+		c.inlineNode(child, &text)
+	})
+	return text.String()
+}
+
+// inlineNode renders a single node as inline Markdown. Block elements nested
+// in inline content are reduced to their inline content.
+func (c *converter) inlineNode(node *goquery.Selection, text *strings.Builder) {
+	switch goquery.NodeName(node) {
+	case "#text":
+		text.WriteString(escapeText(whitespacePattern.ReplaceAllString(node.Text(), " ")))
+	case "#comment":
+		// Comments carry no inline content
+	case "strong", "b":
+		text.WriteString(wrapInline("**", c.inline(node)))
+	case "em", "i":
+		text.WriteString(wrapInline("*", c.inline(node)))
+	case "del", "s", "strike":
+		text.WriteString(wrapInline("~~", c.inline(node)))
+	case "sub", "sup":
+		name := goquery.NodeName(node)
+		text.WriteString(wrapInline("<"+name+">", c.inline(node)))
+	case "code", "tt", "kbd":
+		text.WriteString(codeSpan(node.Text()))
+	case "a":
+		label := strings.TrimSpace(c.inline(node))
+		href, exists := node.Attr("href")
+		if !exists || label == "" {
+			text.WriteString(label)
+			return
+		}
+		text.WriteString("[" + label + "](" + c.resolveHref(href) + ")")
+	case "img":
+		alt, _ := node.Attr("alt")
+		if src, exists := node.Attr("src"); exists {
+			text.WriteString("![" + escapeText(alt) + "](" + src + ")")
+		}
+	case "br":
+		text.WriteString("  \n")
+	case "ac:link":
+		c.processLink(node, text)
+	case "ac:image":
+		c.processImage(node, text)
+	case "ac:structured-macro", "ac:task-list":
+		// Macros render as blocks, keep their content on the current line
+		var block strings.Builder
+		c.processConfluenceMacro(node, &block)
+		text.WriteString(strings.TrimSpace(block.String()))
+	case "p", "div", "h1", "h2", "h3", "h4", "h5", "h6", "li":
+		text.WriteString(" " + c.inline(node) + " ")
+	default:
+		text.WriteString(c.inline(node))
+	}
+}
+
+// wrapInline surrounds text with an inline marker such as ** or *. Leading
+// and trailing whitespace is moved outside the markers, as Markdown doesn't
+// allow it inside them.
+func wrapInline(marker, text string) string {
+	core := strings.TrimSpace(text)
+	if core == "" {
+		return text
+	}
+
+	leading := text[:strings.Index(text, core)]
+	trailing := text[len(leading)+len(core):]
+
+	closing := marker
+	if strings.HasPrefix(marker, "<") {
+		closing = "</" + marker[1:]
+	}
+	return leading + marker + core + closing + trailing
+}
+
+// codeSpan returns text as a code span, using a fence longer than any
+// backtick run in text
+func codeSpan(text string) string {
+	text = whitespacePattern.ReplaceAllString(text, " ")
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	fence := strings.Repeat("`", longest+1)
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+// escapeText escapes the characters of a text node that Markdown would
+// otherwise interpret as formatting
+func escapeText(text string) string {
+	runes := []rune(text)
+
+	var escaped strings.Builder
+	for i, r := range runes {
+		switch r {
+		case '\\', '`', '*', '[', ']':
+			escaped.WriteRune('\\')
+		case '_':
+			// Underscores inside words don't start emphasis
+			if i == 0 || i == len(runes)-1 || !isWordRune(runes[i-1]) || !isWordRune(runes[i+1]) {
+				escaped.WriteRune('\\')
+			}
+		case '<':
+			// Only escape what could be mistaken for an HTML tag
+			if i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || strings.ContainsRune("/!?", runes[i+1])) {
+				escaped.WriteRune('\\')
+			}
+		case '&':
+			// Only escape what could be mistaken for an HTML entity
+			if entityPattern.MatchString(string(runes[i:])) {
+				escaped.WriteRune('\\')
+			}
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// entityPattern matches an HTML entity at the start of a string
+var entityPattern = regexp.MustCompile(`^&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lineStartPattern matches text at the start of a line that Markdown would
+// read as a heading, quote, list item or thematic break
+var lineStartPattern = regexp.MustCompile(`^(\s*)(#|>|[-+](?:\s|$)|=+\s*$|\d+[.)](?:\s|$))`)
+
+// escapeLineStart escapes block syntax at the start of every line of text
+func escapeLineStart(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := lineStartPattern.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+
+		// Escape the marker itself, or the dot or parenthesis of a number
+		pos := m[4]
+		if unicode.IsDigit(rune(line[pos])) {
+			pos = strings.IndexAny(line[pos:], ".)") + pos
+		}
+		lines[i] = line[:pos] + "\\" + line[pos:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,351 @@
+package converter
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"confluence-exporter/internal/models"
+)
+
+// PageRef identifies a page that links can point to
+type PageRef struct {
+	ID       string
+	Title    string
+	SpaceKey string
+	ParentID string
+	// Path is the page's file relative to the output directory, empty if the
+	// output has no file per page
+	Path string
+	// URL is the absolute URL of the page on the Confluence instance
+	URL string
+}
+
+// PageIndex is the set of pages of an export. It is used to resolve links
+// between pages and is safe for concurrent use.
+type PageIndex struct {
+	baseURL string
+
+	mu      sync.RWMutex
+	byID    map[string]PageRef
+	byTitle map[string]PageRef
+}
+
+// NewPageIndex creates an empty index for pages of the Confluence instance at baseURL
+func NewPageIndex(baseURL string) *PageIndex {
+	return &PageIndex{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		byID:    make(map[string]PageRef),
+		byTitle: make(map[string]PageRef),
+	}
+}
+
+// Add adds a page to the index, replacing an earlier entry with the same ID
+func (x *PageIndex) Add(ref PageRef) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.byID[ref.ID] = ref
+	x.byTitle[titleKey(ref.SpaceKey, ref.Title)] = ref
+}
+
+// ByID looks up a page by its ID
+func (x *PageIndex) ByID(id string) (PageRef, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	ref, ok := x.byID[id]
+	return ref, ok
+}
+
+// ByTitle looks up a page by its space key and title
+func (x *PageIndex) ByTitle(spaceKey, title string) (PageRef, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	ref, ok := x.byTitle[titleKey(spaceKey, title)]
+	return ref, ok
+}
+
+// Children returns the pages whose parent is the page with the given ID,
+// sorted by title
+func (x *PageIndex) Children(id string) []PageRef {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var children []PageRef
+	for _, ref := range x.byID {
+		if ref.ParentID == id {
+			children = append(children, ref)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Title < children[j].Title })
+	return children
+}
+
+// titleKey is the byTitle key of a page, titles are unique within a space
+func titleKey(spaceKey, title string) string {
+	return spaceKey + "\x00" + title
+}
+
+var (
+	// pageIDPathPattern matches /spaces/KEY/pages/123/Title style page URLs
+	pageIDPathPattern = regexp.MustCompile(`/pages/(\d+)(?:/|$)`)
+	// displayPathPattern matches /display/KEY/Title style page URLs
+	displayPathPattern = regexp.MustCompile(`/display/([^/]+)/([^/]+)$`)
+)
+
+// processLink converts an ac:link to a Markdown link. Links to pages and
+// anchors are resolved against the page index, without an index only their
+// text is kept. Links to attachments point to the downloaded files.
+func (c *converter) processLink(node *goquery.Selection, markdown *strings.Builder) {
+	anchor, _ := node.Attr("ac:anchor")
+	pageRef := node.Find("ri\\:page")
+	attachment := node.Find("ri\\:attachment")
+
+	// The link body is either rich text or plain text
+	text := trimText(c.inline(node.Find("ac\\:link-body")))
+	if text == "" {
+		text = escapeText(trimText(node.Find("ac\\:plain-text-link-body").Text()))
+	}
+
+	href := ""
+	switch {
+	case pageRef.Length() > 0:
+		title, _ := pageRef.Attr("ri:content-title")
+		spaceKey, _ := pageRef.Attr("ri:space-key")
+		if text == "" {
+			text = escapeText(title)
+		}
+		if title != "" && c.opts.Index != nil {
+			href = c.titleHref(spaceKey, title, anchor)
+		} else if title == "" && anchor != "" {
+			href = "#" + anchor
+		}
+	case attachment.Length() > 0:
+		filename, _ := attachment.Attr("ri:filename")
+		if text == "" {
+			text = escapeText(filename)
+		}
+		if target, ok := c.opts.Attachments[filename]; ok {
+			href = escapeLinkPath(target)
+		} else if c.opts.Index != nil && c.opts.Page.ID != "" {
+			href = c.opts.Index.baseURL + "/download/attachments/" + c.opts.Page.ID + "/" + url.PathEscape(filename)
+		}
+	case anchor != "":
+		if text == "" {
+			text = escapeText(anchor)
+		}
+		href = "#" + anchor
+	}
+
+	if text == "" {
+		text = escapeText(trimText(node.Text()))
+	}
+	if href == "" || (c.opts.Index == nil && attachment.Length() == 0) {
+		markdown.WriteString(text)
+		return
+	}
+	markdown.WriteString("[" + text + "](" + href + ")")
+}
+
+// resolveHref rewrites the target of an HTML link. Links to exported pages
+// become relative paths, other links into Confluence become absolute URLs.
+func (c *converter) resolveHref(href string) string {
+	x := c.opts.Index
+	if x == nil || href == "" || strings.HasPrefix(href, "#") {
+		return href
+	}
+
+	target, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	base, err := url.Parse(x.baseURL + "/")
+	if err != nil {
+		return href
+	}
+	if target.Scheme != "" && target.Scheme != "http" && target.Scheme != "https" {
+		return href // mailto:, tel: and the like
+	}
+	if target.Host != "" && target.Host != base.Host {
+		return href
+	}
+
+	if id, spaceKey, title, ok := pageTarget(target); ok {
+		if id != "" {
+			return c.idHref(id, target.Fragment)
+		}
+		return c.titleHref(spaceKey, title, target.Fragment)
+	}
+
+	// Root-relative links point into the Confluence instance
+	if target.Host == "" && strings.HasPrefix(target.Path, "/") {
+		return base.ResolveReference(target).String()
+	}
+	return href
+}
+
+// pageTarget identifies the Confluence page a URL points to, by its ID or by
+// its space key and title
+func pageTarget(target *url.URL) (id, spaceKey, title string, ok bool) {
+	if id := target.Query().Get("pageId"); id != "" {
+		return id, "", "", true
+	}
+	if m := pageIDPathPattern.FindStringSubmatch(target.Path); m != nil {
+		return m[1], "", "", true
+	}
+	if m := displayPathPattern.FindStringSubmatch(target.Path); m != nil {
+		// Confluence encodes spaces in titles as +
+		return "", m[1], strings.ReplaceAll(m[2], "+", " "), true
+	}
+	return "", "", "", false
+}
+
+// idHref returns the link target for the page with the given ID
+func (c *converter) idHref(id, fragment string) string {
+	if ref, ok := c.opts.Index.ByID(id); ok {
+		return c.pageHref(ref, fragment)
+	}
+	return withFragment(c.opts.Index.baseURL+"/pages/viewpage.action?pageId="+id, fragment)
+}
+
+// titleHref returns the link target for the page with the given title, which
+// is looked up in the current page's space if spaceKey is empty
+func (c *converter) titleHref(spaceKey, title, fragment string) string {
+	if spaceKey == "" {
+		spaceKey = c.opts.Page.SpaceKey
+	}
+	if c.opts.Page.Title == title && c.opts.Page.SpaceKey == spaceKey && fragment != "" {
+		return "#" + fragment
+	}
+	if ref, ok := c.opts.Index.ByTitle(spaceKey, title); ok {
+		return c.pageHref(ref, fragment)
+	}
+	return withFragment(c.opts.Index.baseURL+"/display/"+url.PathEscape(spaceKey)+"/"+url.PathEscape(title), fragment)
+}
+
+// pageHref links to an exported page by its relative path if both pages
+// have files, and by its URL otherwise
+func (c *converter) pageHref(ref PageRef, fragment string) string {
+	if ref.Path == "" || c.opts.Page.Path == "" {
+		return withFragment(ref.URL, fragment)
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(c.opts.Page.Path), ref.Path)
+	if err != nil {
+		return withFragment(ref.URL, fragment)
+	}
+	return withFragment(escapeLinkPath(filepath.ToSlash(rel)), fragment)
+}
+
+// escapeLinkPath escapes the characters that end a Markdown link destination
+func escapeLinkPath(p string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(p)
+}
+
+// withFragment appends a non-empty fragment to link
+func withFragment(link, fragment string) string {
+	if link == "" || fragment == "" {
+		return link
+	}
+	return link + "#" + fragment
+}
+
+// PageLinks returns the distinct links of a page in storage format: page links
+// (ac:link) and HTML links, without anchors within the page and links to
+// attachments. Targets in the Confluence instance are looked up in
+// opts.Index; without an index, only the target of page links and of URLs
+// with a page ID is known.
+func PageLinks(content string, opts Options) ([]models.Link, error) {
+	doc, err := parseStorage(content)
+	if err != nil {
+		return nil, err
+	}
+	c := &converter{opts: opts}
+
+	var links []models.Link
+	seen := make(map[models.Link]bool)
+	doc.Find("ac\\:link > ri\\:page, a[href]").Each(func(i int, node *goquery.Selection) {
+		var link models.Link
+		var ok bool
+		if goquery.NodeName(node) == "a" {
+			href, _ := node.Attr("href")
+			link, ok = c.hrefLink(href)
+		} else {
+			title, _ := node.Attr("ri:content-title")
+			spaceKey, _ := node.Attr("ri:space-key")
+			link, ok = c.titleLink(spaceKey, title)
+		}
+		if ok && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	})
+	return links, nil
+}
+
+// hrefLink describes the target of an HTML link, like resolveHref
+func (c *converter) hrefLink(href string) (models.Link, bool) {
+	if href == "" || strings.HasPrefix(href, "#") {
+		return models.Link{}, false
+	}
+	target, err := url.Parse(href)
+	if err != nil || (target.Scheme != "" && target.Scheme != "http" && target.Scheme != "https") {
+		return models.Link{}, false
+	}
+
+	var base *url.URL
+	if c.opts.Index != nil {
+		base, _ = url.Parse(c.opts.Index.baseURL + "/")
+	}
+	if target.Host != "" && (base == nil || target.Host != base.Host) {
+		return models.Link{URL: href}, true
+	}
+
+	id, spaceKey, title, ok := pageTarget(target)
+	switch {
+	case ok && id != "":
+		link := models.Link{PageID: id, URL: href}
+		if c.opts.Index == nil {
+			return link, true
+		}
+		if ref, found := c.opts.Index.ByID(id); found {
+			return models.Link{PageID: id, SpaceKey: ref.SpaceKey, Title: ref.Title, URL: ref.URL}, true
+		}
+		link.URL = c.opts.Index.baseURL + "/pages/viewpage.action?pageId=" + id
+		return link, true
+	case ok:
+		return c.titleLink(spaceKey, title)
+	case base != nil && target.Host == "" && strings.HasPrefix(target.Path, "/"):
+		return models.Link{URL: base.ResolveReference(target).String()}, true
+	}
+	return models.Link{URL: href}, true
+}
+
+// titleLink describes the target of a link to the page with the given title,
+// which is looked up in the current page's space if spaceKey is empty
+func (c *converter) titleLink(spaceKey, title string) (models.Link, bool) {
+	if title == "" {
+		return models.Link{}, false
+	}
+	if spaceKey == "" {
+		spaceKey = c.opts.Page.SpaceKey
+	}
+
+	link := models.Link{SpaceKey: spaceKey, Title: title}
+	if c.opts.Index == nil {
+		return link, true
+	}
+	if ref, ok := c.opts.Index.ByTitle(spaceKey, title); ok {
+		link.PageID, link.URL = ref.ID, ref.URL
+	} else {
+		link.URL = c.opts.Index.baseURL + "/display/" + url.PathEscape(spaceKey) + "/" + url.PathEscape(title)
+	}
+	return link, true
+}
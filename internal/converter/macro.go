@@ -0,0 +1,172 @@
+package converter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MacroHandler converts a Confluence macro (ac:structured-macro) to Markdown.
+//
+// Convert returns the Markdown for the macro without surrounding blank lines.
+// The converter separates it from the following content, or keeps it on the
+// current line if the macro is used inline, for example in a paragraph.
+type MacroHandler interface {
+	Convert(m *Macro) string
+}
+
+// MacroFunc adapts a function to the MacroHandler interface
+type MacroFunc func(m *Macro) string
+
+// Convert calls f(m)
+func (f MacroFunc) Convert(m *Macro) string {
+	return f(m)
+}
+
+var (
+	macrosMu sync.RWMutex
+	macros   = make(map[string]MacroHandler)
+)
+
+// RegisterMacro makes handler convert the macros with the given ac:name,
+// replacing the built-in handler for that name if there is one. It panics if
+// the name is empty or handler is nil.
+func RegisterMacro(name string, handler MacroHandler) {
+	macrosMu.Lock()
+	defer macrosMu.Unlock()
+
+	if name == "" || handler == nil {
+		panic("converter: RegisterMacro called with empty name or nil handler")
+	}
+	macros[name] = handler
+}
+
+// MacroNames returns the sorted names of all macros with a registered handler
+func MacroNames() []string {
+	macrosMu.RLock()
+	defer macrosMu.RUnlock()
+
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupMacro returns the handler registered for name, or nil
+func lookupMacro(name string) MacroHandler {
+	macrosMu.RLock()
+	defer macrosMu.RUnlock()
+	return macros[name]
+}
+
+// Macro is a macro being converted. It gives handlers access to the macro's
+// parameters and bodies, converted with the settings of the current page.
+type Macro struct {
+	// Name is the ac:name of the macro
+	Name string
+	// Node is the ac:structured-macro element
+	Node *goquery.Selection
+
+	c *converter
+}
+
+// Param returns the value of the named parameter, or "" if it isn't set. The
+// default parameter of macros like anchor has the name "".
+func (m *Macro) Param(name string) string {
+	value := ""
+	m.Node.ChildrenFiltered("ac\\:parameter").EachWithBreak(func(i int, param *goquery.Selection) bool {
+		if paramName, _ := param.Attr("ac:name"); paramName == name {
+			value = strings.TrimSpace(param.Text())
+			return false
+		}
+		return true
+	})
+	return value
+}
+
+// HasBody reports whether the macro has a rich text body
+func (m *Macro) HasBody() bool {
+	return m.body("ac\\:rich-text-body").Length() > 0
+}
+
+// Body returns the rich text body of the macro converted to Markdown
+func (m *Macro) Body() string {
+	return m.Convert(m.body("ac\\:rich-text-body"))
+}
+
+// PlainBody returns the plain text body of the macro, as used by code blocks
+func (m *Macro) PlainBody() string {
+	return strings.Trim(m.body("ac\\:plain-text-body").Text(), "\n")
+}
+
+// body returns the macro's own body element, not one of a nested macro
+func (m *Macro) body(selector string) *goquery.Selection {
+	if body := m.Node.ChildrenFiltered(selector); body.Length() > 0 {
+		return body.First()
+	}
+	return m.Node.Find(selector).First()
+}
+
+// Convert converts the contents of any element to Markdown
+func (m *Macro) Convert(s *goquery.Selection) string {
+	var markdown strings.Builder
+	m.c.processBlocks(s, &markdown)
+	return cleanupMarkdown(markdown.String())
+}
+
+// Options returns the options of the conversion
+func (m *Macro) Options() Options {
+	return m.c.opts
+}
+
+// Page returns the page being converted
+func (m *Macro) Page() PageRef {
+	return m.c.opts.Page
+}
+
+// ChildPages returns the exported child pages of the current page, sorted
+// by title. It is empty if the export has no page index.
+func (m *Macro) ChildPages() []PageRef {
+	if m.c.opts.Index == nil {
+		return nil
+	}
+	return m.c.opts.Index.Children(m.c.opts.Page.ID)
+}
+
+// PageLink returns the link target for an exported page, relative to the
+// current page where possible
+func (m *Macro) PageLink(ref PageRef) string {
+	return m.c.pageHref(ref, "")
+}
+
+// processMacro converts a structured macro with its registered handler
+func (c *converter) processMacro(node *goquery.Selection, markdown *strings.Builder) {
+	name, _ := node.Attr("ac:name")
+	m := &Macro{Name: name, Node: node, c: c}
+
+	var text string
+	if handler := lookupMacro(name); handler != nil {
+		text = handler.Convert(m)
+	} else {
+		text = convertUnknownMacro(m)
+	}
+
+	if text = strings.Trim(text, "\n"); text != "" {
+		markdown.WriteString(text + "\n\n")
+	}
+}
+
+// convertUnknownMacro keeps the body of macros without a handler
+func convertUnknownMacro(m *Macro) string {
+	if m.HasBody() {
+		return m.Body()
+	}
+	if code := m.PlainBody(); code != "" {
+		return codeBlock("", code)
+	}
+	return ""
+}
@@ -0,0 +1,271 @@
+package converter
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func init() {
+	RegisterMacro("code", MacroFunc(convertCodeMacro))
+	RegisterMacro("noformat", MacroFunc(convertNoFormatMacro))
+	for _, name := range []string{"info", "note", "warning", "tip"} {
+		RegisterMacro(name, MacroFunc(convertAdmonitionMacro))
+	}
+	RegisterMacro("panel", MacroFunc(convertPanelMacro))
+	RegisterMacro("quote", MacroFunc(convertQuoteMacro))
+	RegisterMacro("status", MacroFunc(convertStatusMacro))
+	RegisterMacro("expand", MacroFunc(convertExpandMacro))
+	RegisterMacro("toc", MacroFunc(convertTOCMacro))
+	RegisterMacro("children", MacroFunc(convertChildrenMacro))
+	RegisterMacro("anchor", MacroFunc(convertAnchorMacro))
+	RegisterMacro("excerpt", MacroFunc(convertExcerptMacro))
+	RegisterMacro("jira", MacroFunc(convertJiraMacro))
+	// Columns are written one after another
+	RegisterMacro("section", MacroFunc(convertBodyMacro))
+	RegisterMacro("column", MacroFunc(convertBodyMacro))
+}
+
+// convertCodeMacro writes a fenced code block in the macro's language
+func convertCodeMacro(m *Macro) string {
+	return codeBlock(m.Param("language"), m.PlainBody())
+}
+
+// convertNoFormatMacro writes a fenced code block without a language
+func convertNoFormatMacro(m *Macro) string {
+	return codeBlock("", m.PlainBody())
+}
+
+// admonitionTypes maps the info, note, warning and tip panels to the
+// admonition types of each flavor, by the panel's color and meaning
+var admonitionTypes = map[string]map[string]string{
+	"github":     {"info": "NOTE", "tip": "TIP", "note": "WARNING", "warning": "CAUTION"},
+	"mkdocs":     {"info": "info", "tip": "tip", "note": "warning", "warning": "danger"},
+	"docusaurus": {"info": "info", "tip": "tip", "note": "warning", "warning": "danger"},
+}
+
+// convertAdmonitionMacro writes info, note, warning and tip panels in the
+// admonition flavor of the options
+func convertAdmonitionMacro(m *Macro) string {
+	title := m.Param("title")
+	body := m.Body()
+	if title == "" && body == "" {
+		return ""
+	}
+
+	flavor := m.Options().AdmonitionFlavor
+	kind := admonitionTypes[flavor][m.Name]
+	switch flavor {
+	case "github":
+		// Alerts have no title, keep it as the first line
+		if title != "" {
+			body = strings.TrimSpace("**" + escapeText(title) + "**\n\n" + body)
+		}
+		return quote("[!" + kind + "]\n" + body)
+	case "mkdocs":
+		head := "!!! " + kind
+		if title != "" {
+			head += " \"" + strings.ReplaceAll(title, "\"", "'") + "\""
+		}
+		return head + "\n" + indent(body, "    ")
+	case "docusaurus":
+		head := ":::" + kind
+		if title != "" {
+			head += "[" + escapeText(title) + "]"
+		}
+		return head + "\n\n" + body + "\n\n:::"
+	}
+
+	label := "**" + strings.ToUpper(m.Name) + ":**"
+	if title != "" {
+		label += " " + escapeText(title)
+		return quote(strings.TrimSpace(label + "\n\n" + body))
+	}
+
+	// Keep a single paragraph on the line of the label
+	if !strings.Contains(body, "\n") && !lineStartPattern.MatchString(body) {
+		return quote(label + " " + body)
+	}
+	return quote(label + "\n\n" + body)
+}
+
+// convertPanelMacro writes a panel as a quote with its title in bold
+func convertPanelMacro(m *Macro) string {
+	body := m.Body()
+	if title := m.Param("title"); title != "" {
+		body = strings.TrimSpace("**" + escapeText(title) + "**\n\n" + body)
+	}
+	return quote(body)
+}
+
+// convertQuoteMacro writes the body as a quote
+func convertQuoteMacro(m *Macro) string {
+	return quote(m.Body())
+}
+
+// convertStatusMacro writes a status lozenge as bold text
+func convertStatusMacro(m *Macro) string {
+	title := m.Param("title")
+	if title == "" {
+		title = m.Param("colour")
+	}
+	if title == "" {
+		return ""
+	}
+	return "**" + escapeText(strings.ToUpper(title)) + "**"
+}
+
+// convertExpandMacro writes a collapsible <details> block
+func convertExpandMacro(m *Macro) string {
+	title := m.Param("title")
+	if title == "" {
+		title = "Click here to expand..."
+	}
+	return "<details>\n<summary>" + html.EscapeString(title) + "</summary>\n\n" + m.Body() + "\n\n</details>"
+}
+
+// convertTOCMacro writes a list of links to the headings of the page
+func convertTOCMacro(m *Macro) string {
+	minLevel, maxLevel := 1, 6
+	if level, err := strconv.Atoi(m.Param("minLevel")); err == nil {
+		minLevel = level
+	}
+	if level, err := strconv.Atoi(m.Param("maxLevel")); err == nil {
+		maxLevel = level
+	}
+
+	type heading struct {
+		level int
+		text  string
+	}
+	var headings []heading
+	top := maxLevel
+	m.Node.Parents().Last().Find("h1, h2, h3, h4, h5, h6").Each(func(i int, h *goquery.Selection) {
+		level := int(goquery.NodeName(h)[1] - '0')
+		text := strings.Join(strings.Fields(h.Text()), " ")
+		if level < minLevel || level > maxLevel || text == "" {
+			return
+		}
+		headings = append(headings, heading{level, text})
+		top = min(top, level)
+	})
+
+	var toc strings.Builder
+	slugs := make(map[string]int)
+	for _, h := range headings {
+		toc.WriteString(strings.Repeat("  ", h.level-top) + "- [" + escapeText(h.text) + "](#" + headingSlug(h.text, slugs) + ")\n")
+	}
+	return toc.String()
+}
+
+// headingSlug returns the anchor GitHub generates for a heading, counting
+// duplicates in seen
+func headingSlug(text string, seen map[string]int) string {
+	var slug strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			slug.WriteRune(r)
+		case r == ' ':
+			slug.WriteRune('-')
+		}
+	}
+
+	s := slug.String()
+	if n := seen[s]; n > 0 {
+		seen[s] = n + 1
+		return s + "-" + strconv.Itoa(n)
+	}
+	seen[s] = 1
+	return s
+}
+
+// convertChildrenMacro writes a list of links to the exported child pages
+func convertChildrenMacro(m *Macro) string {
+	var list strings.Builder
+	for _, child := range m.ChildPages() {
+		list.WriteString("- [" + escapeText(child.Title) + "](" + m.PageLink(child) + ")\n")
+	}
+	return list.String()
+}
+
+// convertAnchorMacro writes an HTML anchor that links to the macro can target
+func convertAnchorMacro(m *Macro) string {
+	name := m.Param("")
+	if name == "" {
+		return ""
+	}
+	return "<a id=\"" + html.EscapeString(name) + "\"></a>"
+}
+
+// convertExcerptMacro keeps the excerpt unless it is hidden on the page
+func convertExcerptMacro(m *Macro) string {
+	if m.Param("hidden") == "true" {
+		return ""
+	}
+	return m.Body()
+}
+
+// convertJiraMacro writes the issue key or the JQL query of a Jira macro
+func convertJiraMacro(m *Macro) string {
+	if key := m.Param("key"); key != "" {
+		return escapeText(key)
+	}
+	if jql := m.Param("jqlQuery"); jql != "" {
+		return "Jira issues: " + codeSpan(jql)
+	}
+	return ""
+}
+
+// convertBodyMacro writes the body of the macro
+func convertBodyMacro(m *Macro) string {
+	return m.Body()
+}
+
+// codeBlock returns code as a fenced code block, using a fence longer than
+// any backtick run in the code
+func codeBlock(language, code string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// indent prefixes every non-empty line of text with prefix
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// quote prefixes every line of text with the blockquote marker
+func quote(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
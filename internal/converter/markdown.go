@@ -2,85 +2,114 @@ package converter
 
 import (
 	"fmt"
+	"html"
 	"regexp"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
+// Options controls the conversion of a page
+type Options struct {
+	// Index resolves links to other pages. Links are copied verbatim if nil.
+	Index *PageIndex
+	// Page is the page being converted, relative links start at its Path
+	Page PageRef
+	// Attachments maps the file names of the page's attachments to the link
+	// targets of the downloaded files
+	Attachments map[string]string
+	// HTMLTables writes tables with merged cells or block content as HTML
+	// instead of flattening them into Markdown tables
+	HTMLTables bool
+	// AdmonitionFlavor selects how info, note, warning and tip panels are
+	// written: "github" alerts, "mkdocs" or "docusaurus" admonitions, or
+	// blockquotes if empty
+	AdmonitionFlavor string
+	// ImageFormat selects how image size and captions are written:
+	// "markdown" keeps the caption as title, "attributes" adds a Pandoc style
+	// {width="200" height="100"} list and "html" writes <img> tags
+	ImageFormat string
+}
+
+// converter holds the state of a single page conversion
+type converter struct {
+	opts Options
+}
+
 // ConvertToMarkdown transforms Confluence HTML content to Markdown
-func ConvertToMarkdown(content string) (string, error) {
+func ConvertToMarkdown(content string, opts Options) (string, error) {
+	c := &converter{opts: opts}
+
 	// Parse the HTML content
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	doc, err := parseStorage(content)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse HTML: %v", err)
+		return "", err
 	}
 
 	// Process the document
 	var markdown strings.Builder
-	processNode(doc.Selection, &markdown, 0)
+	c.processNode(doc.Selection, &markdown, 0)
 
 	// Clean up the result
 	result := cleanupMarkdown(markdown.String())
 	return result, nil
 }
 
+// cdataPattern matches the CDATA sections of code and plain text bodies
+var cdataPattern = regexp.MustCompile(`(?s)<!\[CDATA\[(.*?)\]\]>`)
+
+// selfClosingPattern matches self-closing Confluence elements like
+// <ac:structured-macro ac:name="toc"/>
+var selfClosingPattern = regexp.MustCompile(`<((?:ac|ri):[\w-]+)([^<>]*?)\s*/>`)
+
+// parseStorage parses Confluence storage format. The HTML parser doesn't
+// support CDATA sections, so they are turned into escaped text first. It also
+// ignores the slash of self-closing tags it doesn't know, which would nest the
+// rest of the page inside the element, so those get an end tag.
+func parseStorage(content string) (*goquery.Document, error) {
+	content = cdataPattern.ReplaceAllStringFunc(content, func(section string) string {
+		return html.EscapeString(cdataPattern.FindStringSubmatch(section)[1])
+	})
+	content = selfClosingPattern.ReplaceAllString(content, "<$1$2></$1>")
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %v", err)
+	}
+	return doc, nil
+}
+
 // processNode recursively converts HTML nodes to Markdown
-func processNode(s *goquery.Selection, markdown *strings.Builder, depth int) {
+func (c *converter) processNode(s *goquery.Selection, markdown *strings.Builder, depth int) {
 	s.Each(func(i int, node *goquery.Selection) {
 		// Check for Confluence specific macros and elements
-		if processConfluenceMacro(node, markdown) {
+		if c.processConfluenceMacro(node, markdown) {
 			return // Skip regular processing if this was a special macro
 		}
 
 		// Process based on HTML element
 		switch goquery.NodeName(node) {
-		case "h1":
-			markdown.WriteString("# " + node.Text() + "\n\n")
-		case "h2":
-			markdown.WriteString("## " + node.Text() + "\n\n")
-		case "h3":
-			markdown.WriteString("### " + node.Text() + "\n\n")
-		case "h4":
-			markdown.WriteString("#### " + node.Text() + "\n\n")
-		case "h5":
-			markdown.WriteString("##### " + node.Text() + "\n\n")
-		case "h6":
-			markdown.WriteString("###### " + node.Text() + "\n\n")
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			level := int(goquery.NodeName(node)[1] - '0')
+			text := strings.Join(strings.Fields(c.inline(node)), " ")
+			if text != "" {
+				markdown.WriteString(strings.Repeat("#", level) + " " + text + "\n\n")
+			}
 		case "p":
-			text := trimText(node.Text())
+			text := escapeLineStart(trimText(c.inline(node)))
 			if text != "" {
 				markdown.WriteString(text + "\n\n")
 			}
-		case "ul":
-			processChildren(node, markdown, depth)
-			markdown.WriteString("\n")
-		case "ol":
-			processChildren(node, markdown, depth)
-			markdown.WriteString("\n")
-		case "li":
-			indent := strings.Repeat("  ", depth)
-			if node.Parent().Is("ol") {
-				index := node.Index() + 1
-				markdown.WriteString(indent + fmt.Sprintf("%d. ", index))
-			} else {
-				markdown.WriteString(indent + "- ")
-			}
-			processChildren(node, markdown, depth+1)
-			markdown.WriteString("\n")
-		case "a":
-			href, exists := node.Attr("href")
-			if exists {
-				markdown.WriteString("[" + node.Text() + "](" + href + ")")
-			} else {
-				markdown.WriteString(node.Text())
+		case "ul", "ol":
+			c.processChildren(node, markdown, depth)
+			// Nested lists continue the list of their parent item
+			if depth == 0 {
+				markdown.WriteString("\n")
 			}
-		case "strong", "b":
-			markdown.WriteString("**" + node.Text() + "**")
-		case "em", "i":
-			markdown.WriteString("*" + node.Text() + "*")
-		case "code":
-			markdown.WriteString("`" + node.Text() + "`")
+		case "li":
+			c.processListItem(node, markdown, depth)
+		case "a", "strong", "b", "em", "i", "del", "s", "strike", "sub", "sup", "code", "tt", "kbd", "img", "br":
+			c.inlineNode(node, markdown)
 		case "pre":
 			language := ""
 			if node.Find("code").Length() > 0 {
@@ -89,57 +118,35 @@ func processNode(s *goquery.Selection, markdown *strings.Builder, depth int) {
 					language = strings.TrimPrefix(codeClass, "language-")
 				}
 			}
-			markdown.WriteString("```" + language + "\n" + node.Text() + "\n```\n\n")
-		case "br":
-			markdown.WriteString("\n")
+			markdown.WriteString(codeBlock(language, strings.Trim(node.Text(), "\n")) + "\n\n")
 		case "hr":
 			markdown.WriteString("---\n\n")
-		case "img":
-			alt, _ := node.Attr("alt")
-			src, exists := node.Attr("src")
-			if exists {
-				markdown.WriteString("![" + alt + "](" + src + ")")
-			}
 		case "table":
-			processTable(node, markdown)
+			c.processTable(node, markdown)
 		case "div", "span":
 			// For general containers, just process their children
-			processChildren(node, markdown, depth)
+			c.processChildren(node, markdown, depth)
 		case "#text":
 			text := trimText(node.Text())
 			if text != "" {
-				markdown.WriteString(text)
+				markdown.WriteString(escapeText(text))
 			}
 		default:
 			// For other elements, just process their children
-			processChildren(node, markdown, depth)
+			c.processChildren(node, markdown, depth)
 		}
 	})
 }
 
 // processConfluenceMacro handles specific Confluence macros and elements
-func processConfluenceMacro(node *goquery.Selection, markdown *strings.Builder) bool {
+func (c *converter) processConfluenceMacro(node *goquery.Selection, markdown *strings.Builder) bool {
 	// Check for ac: namespaced elements
 	if namespace, _ := node.Attr("xmlns:ac"); namespace != "" ||
 		strings.HasPrefix(goquery.NodeName(node), "ac:") {
 
-		// Handle structured macros (info panels, notes, code blocks, etc)
+		// Handle structured macros with their registered handlers
 		if node.Is("ac\\:structured-macro") || node.HasClass("confluence-structured-macro") {
-			macroType, _ := node.Attr("ac:name")
-			switch macroType {
-			case "code":
-				// Code block macro
-				language := node.Find("ac\\:parameter[ac\\:name='language']").Text()
-				code := node.Find("ac\\:plain-text-body").Text()
-				markdown.WriteString("```" + language + "\n" + code + "\n```\n\n")
-			case "info", "note", "warning", "tip":
-				// Info/Note/Warning/Tip macro
-				content := node.Find(".confluence-information-macro-body").Text()
-				markdown.WriteString("> **" + strings.ToUpper(macroType) + ":** " + content + "\n\n")
-			default:
-				// Other macros - extract any text content
-				markdown.WriteString(node.Text() + "\n\n")
-			}
+			c.processMacro(node, markdown)
 			return true
 		}
 
@@ -147,7 +154,7 @@ func processConfluenceMacro(node *goquery.Selection, markdown *strings.Builder)
 		if node.Is("ac\\:task-list") || node.HasClass("task-list") {
 			node.Find("ac\\:task").Each(func(i int, task *goquery.Selection) {
 				completed, _ := task.Find("ac\\:task-status").Attr("ac:name")
-				taskText := task.Find("ac\\:task-body").Text()
+				taskText := trimText(c.inline(task.Find("ac\\:task-body")))
 
 				if completed == "complete" {
 					markdown.WriteString("- [x] " + taskText + "\n")
@@ -159,11 +166,16 @@ func processConfluenceMacro(node *goquery.Selection, markdown *strings.Builder)
 			return true
 		}
 
+		// Handle links to pages, attachments and anchors
+		if node.Is("ac\\:link") {
+			c.processLink(node, markdown)
+			return true
+		}
+
 		// Handle Confluence images
 		if node.Is("ac\\:image") || node.HasClass("confluence-embedded-image") {
-			imgSrc, _ := node.Find("ri\\:url").Attr("ri:value")
-			alt := node.Find("ac\\:alt").Text()
-			markdown.WriteString("![" + alt + "](" + imgSrc + ")\n\n")
+			c.processImage(node, markdown)
+			markdown.WriteString("\n\n")
 			return true
 		}
 	}
@@ -171,82 +183,67 @@ func processConfluenceMacro(node *goquery.Selection, markdown *strings.Builder)
 	return false
 }
 
-// processTable converts HTML tables to Markdown tables
-func processTable(table *goquery.Selection, markdown *strings.Builder) {
-	var rows [][]string
-
-	// Process header row
-	var headers []string
-	table.Find("thead tr th").Each(func(i int, th *goquery.Selection) {
-		headers = append(headers, th.Text())
-	})
-	if len(headers) > 0 {
-		rows = append(rows, headers)
+// processListItem converts a list item. Its inline content and paragraphs
+// are written on the item's line, nested lists are indented below it.
+func (c *converter) processListItem(node *goquery.Selection, markdown *strings.Builder, depth int) {
+	marker := "- "
+	if node.Parent().Is("ol") {
+		marker = fmt.Sprintf("%d. ", node.Index()+1)
 	}
 
-	// Process body rows
-	table.Find("tbody tr").Each(func(i int, tr *goquery.Selection) {
-		var row []string
-		tr.Find("td").Each(func(j int, td *goquery.Selection) {
-			row = append(row, td.Text())
-		})
-		rows = append(rows, row)
-	})
-
-	// Build the markdown table
-	if len(rows) > 0 {
-		// Determine column count from the row with most cells
-		maxCols := 0
-		for _, row := range rows {
-			if len(row) > maxCols {
-				maxCols = len(row)
-			}
+	var text, nested strings.Builder
+	node.Contents().Each(func(i int, child *goquery.Selection) {
+		switch goquery.NodeName(child) {
+		case "ul", "ol":
+			c.processNode(child, &nested, depth+1)
+		case "p":
+			text.WriteString(" " + c.inline(child) + " ")
+		default:
+			c.inlineNode(child, &text)
 		}
+	})
 
-		// Print header row
-		if len(headers) > 0 {
-			for i := 0; i < len(headers); i++ {
-				if i > 0 {
-					markdown.WriteString(" | ")
-				}
-				markdown.WriteString(headers[i])
-			}
-			markdown.WriteString("\n")
+	indent := strings.Repeat("  ", depth)
+	item := escapeLineStart(trimText(text.String()))
+	// Continuation lines of hard line breaks belong to the item
+	item = strings.ReplaceAll(item, "\n", "\n"+indent+strings.Repeat(" ", len(marker)))
+	markdown.WriteString(indent + marker + item + "\n" + nested.String())
+}
 
-			// Print separator row
-			for i := 0; i < len(headers); i++ {
-				if i > 0 {
-					markdown.WriteString(" | ")
-				}
-				markdown.WriteString("---")
-			}
-			markdown.WriteString("\n")
-		}
+// blockElements are the elements processBlocks starts a new block for
+var blockElements = map[string]bool{
+	"p": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "table": true, "pre": true, "blockquote": true, "hr": true,
+	"ac:structured-macro": true, "ac:task-list": true, "ac:layout": true,
+}
 
-		// Print data rows
-		rowStart := 0
-		if len(headers) > 0 {
-			rowStart = 1
+// processBlocks converts the children of a container that mixes inline
+// content with block elements, such as a table cell. Runs of inline content
+// become paragraphs.
+func (c *converter) processBlocks(s *goquery.Selection, markdown *strings.Builder) {
+	var text strings.Builder
+	flush := func() {
+		if paragraph := escapeLineStart(trimText(text.String())); paragraph != "" {
+			markdown.WriteString(paragraph + "\n\n")
 		}
+		text.Reset()
+	}
 
-		for i := rowStart; i < len(rows); i++ {
-			for j := 0; j < len(rows[i]); j++ {
-				if j > 0 {
-					markdown.WriteString(" | ")
-				}
-				markdown.WriteString(rows[i][j])
-			}
-			markdown.WriteString("\n")
+	s.Contents().Each(func(i int, child *goquery.Selection) {
+		if blockElements[goquery.NodeName(child)] {
+			flush()
+			c.processNode(child, markdown, 0)
+			return
 		}
-
-		markdown.WriteString("\n")
-	}
+		c.inlineNode(child, &text)
+	})
+	flush()
 }
 
 // processChildren processes child nodes
-func processChildren(s *goquery.Selection, markdown *strings.Builder, depth int) {
+func (c *converter) processChildren(s *goquery.Selection, markdown *strings.Builder, depth int) {
 	s.Contents().Each(func(i int, child *goquery.Selection) {
-		processNode(child, markdown, depth)
+		c.processNode(child, markdown, depth)
 	})
 }
 
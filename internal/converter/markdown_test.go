@@ -0,0 +1,514 @@
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// conversionTest is a storage format snippet and the Markdown it converts to
+type conversionTest struct {
+	name    string
+	storage string
+	opts    Options
+	want    string
+}
+
+func runConversionTests(t *testing.T, tests []conversionTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertToMarkdown(tt.storage, tt.opts)
+			if err != nil {
+				t.Fatalf("ConvertToMarkdown: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertToMarkdown() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// testIndex is the page index of an export of the spaces TEAM and OPS
+func testIndex() *PageIndex {
+	index := NewPageIndex("https://example.atlassian.net/wiki/")
+	index.Add(PageRef{ID: "1", Title: "Home", SpaceKey: "TEAM", Path: "TEAM/Home.md", URL: "https://example.atlassian.net/wiki/spaces/TEAM/pages/1/Home"})
+	index.Add(PageRef{ID: "2", Title: "Setup Guide", SpaceKey: "TEAM", ParentID: "1", Path: "TEAM/Setup_Guide.md", URL: "https://example.atlassian.net/wiki/spaces/TEAM/pages/2/Setup+Guide"})
+	index.Add(PageRef{ID: "3", Title: "Runbook", SpaceKey: "OPS", Path: "OPS/Runbook.md", URL: "https://example.atlassian.net/wiki/spaces/OPS/pages/3/Runbook"})
+	return index
+}
+
+// homePage is the page being converted in the link tests
+var homePage = PageRef{ID: "1", Title: "Home", SpaceKey: "TEAM", Path: "TEAM/Home.md"}
+
+func TestConvertLinks(t *testing.T) {
+	opts := Options{Index: testIndex(), Page: homePage}
+	runConversionTests(t, []conversionTest{
+		{
+			name:    "page by title in the same space",
+			storage: `<p><ac:link><ri:page ri:content-title="Setup Guide"/></ac:link></p>`,
+			opts:    opts,
+			want:    "[Setup Guide](Setup_Guide.md)",
+		},
+		{
+			name:    "page by title with anchor and plain text body",
+			storage: `<p><ac:link ac:anchor="install"><ri:page ri:content-title="Setup Guide"/><ac:plain-text-link-body><![CDATA[Install]]></ac:plain-text-link-body></ac:link></p>`,
+			opts:    opts,
+			want:    "[Install](Setup_Guide.md#install)",
+		},
+		{
+			name:    "page by title in another space with rich text body",
+			storage: `<p><ac:link><ri:page ri:space-key="OPS" ri:content-title="Runbook"/><ac:link-body><strong>runbook</strong></ac:link-body></ac:link></p>`,
+			opts:    opts,
+			want:    "[**runbook**](../OPS/Runbook.md)",
+		},
+		{
+			name:    "page by title that wasn't exported",
+			storage: `<p><ac:link><ri:page ri:content-title="Missing Page"/></ac:link></p>`,
+			opts:    opts,
+			want:    "[Missing Page](https://example.atlassian.net/wiki/display/TEAM/Missing%20Page)",
+		},
+		{
+			name:    "page by ID in an absolute URL",
+			storage: `<p><a href="https://example.atlassian.net/wiki/spaces/OPS/pages/3/Runbook#steps">steps</a></p>`,
+			opts:    opts,
+			want:    "[steps](../OPS/Runbook.md#steps)",
+		},
+		{
+			name:    "page by ID in a root-relative URL",
+			storage: `<p><a href="/wiki/spaces/TEAM/pages/2/Setup+Guide">guide</a></p>`,
+			opts:    opts,
+			want:    "[guide](Setup_Guide.md)",
+		},
+		{
+			name:    "page by ID that wasn't exported",
+			storage: `<p><a href="/wiki/spaces/TEAM/pages/99/Gone">gone</a></p>`,
+			opts:    opts,
+			want:    "[gone](https://example.atlassian.net/wiki/pages/viewpage.action?pageId=99)",
+		},
+		{
+			name:    "display URL",
+			storage: `<p><a href="/wiki/display/OPS/Runbook">runbook</a></p>`,
+			opts:    opts,
+			want:    "[runbook](../OPS/Runbook.md)",
+		},
+		{
+			name:    "external URL",
+			storage: `<p><a href="https://github.com/org/repo">repo</a></p>`,
+			opts:    opts,
+			want:    "[repo](https://github.com/org/repo)",
+		},
+		{
+			name:    "anchor on the same page",
+			storage: `<p><ac:link ac:anchor="top"><ac:plain-text-link-body><![CDATA[Top]]></ac:plain-text-link-body></ac:link></p>`,
+			opts:    opts,
+			want:    "[Top](#top)",
+		},
+		{
+			name:    "page without index",
+			storage: `<p><ac:link><ri:page ri:content-title="Setup Guide"/></ac:link></p>`,
+			opts:    Options{Page: homePage},
+			want:    "Setup Guide",
+		},
+		{
+			name:    "URL without index",
+			storage: `<p><a href="/wiki/spaces/TEAM/pages/2/Setup+Guide">guide</a></p>`,
+			opts:    Options{Page: homePage},
+			want:    "[guide](/wiki/spaces/TEAM/pages/2/Setup+Guide)",
+		},
+	})
+}
+
+func TestPageLinks(t *testing.T) {
+	storage := `<p><ac:link><ri:page ri:content-title="Setup Guide"/></ac:link>
+		<a href="/wiki/spaces/OPS/pages/3/Runbook">runbook</a>
+		<a href="#top">top</a>
+		<a href="https://github.com">GitHub</a>
+		<ac:link><ri:page ri:content-title="Setup Guide"/></ac:link></p>`
+
+	links, err := PageLinks(storage, Options{Index: testIndex(), Page: homePage})
+	if err != nil {
+		t.Fatalf("PageLinks: %v", err)
+	}
+	want := "[{2 TEAM Setup Guide https://example.atlassian.net/wiki/spaces/TEAM/pages/2/Setup+Guide} " +
+		"{3 OPS Runbook https://example.atlassian.net/wiki/spaces/OPS/pages/3/Runbook} " +
+		"{   https://github.com}]"
+	if got := fmt.Sprint(links); got != want {
+		t.Errorf("PageLinks() = %s, want %s", got, want)
+	}
+}
+
+func TestConvertImages(t *testing.T) {
+	attachments := map[string]string{"arch diagram.png": "Home_attachments/arch diagram.png"}
+	captioned := `<ac:image ac:alt="Architecture" ac:width="400" ac:height="200"><ri:attachment ri:filename="arch diagram.png"/><ac:caption><p>System overview</p></ac:caption></ac:image>`
+	inline := `<p>See <ac:image ac:alt="icon" ac:width="16"><ri:attachment ri:filename="arch diagram.png"/></ac:image> here</p>`
+	external := `<ac:image ac:alt="Logo"><ri:url ri:value="https://example.com/logo.png"/></ac:image>`
+	otherPage := `<ac:image ac:alt="Other page's image"><ri:attachment ri:filename="x.png"><ri:page ri:content-title="Other"/></ri:attachment></ac:image>`
+
+	var tests []conversionTest
+	for _, format := range []struct {
+		name      string
+		captioned string
+		inline    string
+		external  string
+	}{
+		{
+			name:      "markdown",
+			captioned: `![Architecture](Home_attachments/arch%20diagram.png "System overview")`,
+			inline:    "See ![icon](Home_attachments/arch%20diagram.png) here",
+			external:  "![Logo](https://example.com/logo.png)",
+		},
+		{
+			name:      "attributes",
+			captioned: `![Architecture](Home_attachments/arch%20diagram.png "System overview"){width="400" height="200"}`,
+			inline:    `See ![icon](Home_attachments/arch%20diagram.png){width="16"} here`,
+			external:  "![Logo](https://example.com/logo.png)",
+		},
+		{
+			name:      "html",
+			captioned: `<figure><img src="Home_attachments/arch%20diagram.png" alt="Architecture" width="400" height="200"><figcaption>System overview</figcaption></figure>`,
+			inline:    `See <img src="Home_attachments/arch%20diagram.png" alt="icon" width="16"> here`,
+			external:  `<img src="https://example.com/logo.png" alt="Logo">`,
+		},
+	} {
+		opts := Options{Attachments: attachments, ImageFormat: format.name}
+		tests = append(tests,
+			conversionTest{name: format.name + " with caption and size", storage: captioned, opts: opts, want: format.captioned},
+			conversionTest{name: format.name + " inline", storage: inline, opts: opts, want: format.inline},
+			conversionTest{name: format.name + " external", storage: external, opts: opts, want: format.external},
+			conversionTest{name: format.name + " attachment of another page", storage: otherPage, opts: opts, want: "Other page's image"},
+		)
+	}
+	runConversionTests(t, tests)
+}
+
+func TestImageAttachments(t *testing.T) {
+	storage := `<ac:image><ri:attachment ri:filename="a.png"/></ac:image>
+		<ac:image><ri:url ri:value="https://example.com/logo.png"/></ac:image>
+		<ac:image><ri:attachment ri:filename="x.png"><ri:page ri:content-title="Other"/></ri:attachment></ac:image>
+		<p><ac:image><ri:attachment ri:filename="b.png"/></ac:image><ac:image><ri:attachment ri:filename="a.png"/></ac:image></p>
+		<ac:link><ri:attachment ri:filename="report.pdf"/></ac:link>`
+
+	names, err := ImageAttachments(storage)
+	if err != nil {
+		t.Fatalf("ImageAttachments: %v", err)
+	}
+	if got := fmt.Sprint(names); got != "[a.png b.png]" {
+		t.Errorf("ImageAttachments() = %s, want [a.png b.png]", got)
+	}
+}
+
+func TestConvertInlineFormatting(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{
+			name:    "nested emphasis",
+			storage: `<p><strong>bold <em>and italic</em></strong> text</p>`,
+			want:    "**bold *and italic*** text",
+		},
+		{
+			name:    "three levels",
+			storage: `<p><em>x <strong>y <del>z</del></strong></em></p>`,
+			want:    "*x **y ~~z~~***",
+		},
+		{
+			name:    "whitespace moved outside markers",
+			storage: `<p>Some<strong> spaced </strong>words</p>`,
+			want:    "Some **spaced** words",
+		},
+		{
+			name:    "empty markers are dropped",
+			storage: `<p><strong></strong>empty</p>`,
+			want:    "empty",
+		},
+		{
+			name:    "subscript and superscript",
+			storage: `<p>H<sub>2</sub>O and x<sup>2</sup></p>`,
+			want:    "H<sub>2</sub>O and x<sup>2</sup>",
+		},
+		{
+			name:    "code spans are not escaped",
+			storage: "<p>Use <code>a*b</code> and <code>x `y</code></p>",
+			want:    "Use `a*b` and ``x `y``",
+		},
+		{
+			name:    "formatting inside a link",
+			storage: `<p><a href="https://example.com"><strong>bold link</strong></a></p>`,
+			want:    "[**bold link**](https://example.com)",
+		},
+		{
+			name:    "hard line break",
+			storage: `<p>line one<br/>line two</p>`,
+			want:    "line one  \nline two",
+		},
+		{
+			name:    "formatting in nested list items",
+			storage: `<ul><li>one <strong>bold</strong></li><li><p>two</p><ul><li>nested <em>it</em></li></ul></li></ul>`,
+			want:    "- one **bold**\n- two\n  - nested *it*",
+		},
+	})
+}
+
+func TestConvertEscaping(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{
+			name:    "emphasis and link characters",
+			storage: `<p>2 * 3 = 6, [note] and a\b</p>`,
+			want:    `2 \* 3 = 6, \[note\] and a\\b`,
+		},
+		{
+			name:    "underscores within words",
+			storage: `<p>snake_case and _private_</p>`,
+			want:    `snake_case and \_private\_`,
+		},
+		{
+			name:    "heading at line start",
+			storage: `<p># not a heading</p>`,
+			want:    `\# not a heading`,
+		},
+		{
+			name:    "ordered list at line start",
+			storage: `<p>1. not a list</p>`,
+			want:    `1\. not a list`,
+		},
+		{
+			name:    "bullet at line start",
+			storage: `<p>- not a bullet</p>`,
+			want:    `\- not a bullet`,
+		},
+		{
+			name:    "HTML tags and entities",
+			storage: `<p>&lt;div&gt; and a &lt; b &amp; c, &amp;amp;</p>`,
+			want:    `\<div> and a < b & c, \&amp;`,
+		},
+	})
+}
+
+func TestCodeSpan(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "plain", want: "`plain`"},
+		{text: "a `b` c", want: "``a `b` c``"},
+		{text: "``x", want: "``` ``x ```"},
+		{text: "multi\nline", want: "`multi line`"},
+		{text: " ", want: " "},
+	}
+	for _, tt := range tests {
+		if got := codeSpan(tt.text); got != tt.want {
+			t.Errorf("codeSpan(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestConvertTables(t *testing.T) {
+	blockCell := `<table><tbody><tr><th>Step</th><th>Details</th></tr><tr><td>1</td><td><p>First</p><ul><li>a</li><li>b</li></ul></td></tr></tbody></table>`
+	merged := `<table><thead><tr><td>H1</td><td>H2</td></tr></thead><tbody><tr><td colspan="2">wide</td></tr><tr><td rowspan="2">tall</td><td>1</td></tr><tr><td>2</td></tr></tbody></table>`
+
+	runConversionTests(t, []conversionTest{
+		{
+			name:    "header row and escaped pipe",
+			storage: `<table><tbody><tr><th>Name</th><th>Role</th></tr><tr><td>Ann</td><td>Dev | Ops</td></tr></tbody></table>`,
+			want:    "| Name | Role       |\n| ---- | ---------- |\n| Ann  | Dev \\| Ops |",
+		},
+		{
+			name:    "no header row and a short row",
+			storage: `<table><tbody><tr><td>a</td><td>b</td></tr><tr><td>c</td></tr></tbody></table>`,
+			want:    "|     |     |\n| --- | --- |\n| a   | b   |\n| c   |     |",
+		},
+		{
+			name:    "header column",
+			storage: `<table><tbody><tr><th>Key</th><td>Value</td></tr><tr><th>Other</th><td>x</td></tr></tbody></table>`,
+			want:    "|           |       |\n| --------- | ----- |\n| **Key**   | Value |\n| **Other** | x     |",
+		},
+		{
+			name:    "block cell flattened",
+			storage: blockCell,
+			want:    "| Step | Details             |\n| ---- | ------------------- |\n| 1    | First<br>- a<br>- b |",
+		},
+		{
+			name:    "merged cells aligned",
+			storage: merged,
+			want:    "| H1   | H2  |\n| ---- | --- |\n| wide |     |\n| tall | 1   |\n|      | 2   |",
+		},
+		{
+			name:    "block cell as HTML",
+			storage: blockCell,
+			opts:    Options{HTMLTables: true},
+			want: "<table>\n<tr>\n<th>\n\nStep\n\n</th>\n<th>\n\nDetails\n\n</th>\n</tr>\n" +
+				"<tr>\n<td>\n\n1\n\n</td>\n<td>\n\nFirst\n\n- a\n- b\n\n</td>\n</tr>\n</table>",
+		},
+		{
+			name:    "merged cells as HTML",
+			storage: merged,
+			opts:    Options{HTMLTables: true},
+			want: "<table>\n<tr>\n<th>\n\nH1\n\n</th>\n<th>\n\nH2\n\n</th>\n</tr>\n" +
+				"<tr>\n<td colspan=\"2\">\n\nwide\n\n</td>\n</tr>\n" +
+				"<tr>\n<td rowspan=\"2\">\n\ntall\n\n</td>\n<td>\n\n1\n\n</td>\n</tr>\n" +
+				"<tr>\n<td>\n\n2\n\n</td>\n</tr>\n</table>",
+		},
+		{
+			name:    "simple table stays Markdown with HTML fallback",
+			storage: `<table><tbody><tr><th>A</th></tr><tr><td>1</td></tr></tbody></table>`,
+			opts:    Options{HTMLTables: true},
+			want:    "| A   |\n| --- |\n| 1   |",
+		},
+	})
+}
+
+func TestConvertMacros(t *testing.T) {
+	RegisterMacro("test-badge", MacroFunc(func(m *Macro) string {
+		label := "[" + m.Param("label") + "]"
+		if m.HasBody() {
+			return label + " " + m.Body()
+		}
+		return label
+	}))
+	if !slices.Contains(MacroNames(), "test-badge") {
+		t.Errorf("MacroNames() = %v, want it to contain test-badge", MacroNames())
+	}
+
+	runConversionTests(t, []conversionTest{
+		{
+			name: "custom macro as a block",
+			storage: `<ac:structured-macro ac:name="test-badge"><ac:parameter ac:name="label">beta</ac:parameter>` +
+				`<ac:rich-text-body><p>Subject to <em>change</em></p></ac:rich-text-body></ac:structured-macro><p>After</p>`,
+			want: "[beta] Subject to *change*\n\nAfter",
+		},
+		{
+			name:    "custom macro inline",
+			storage: `<p>Status: <ac:structured-macro ac:name="test-badge"><ac:parameter ac:name="label">new</ac:parameter></ac:structured-macro> today</p>`,
+			want:    "Status: [new] today",
+		},
+		{
+			name: "code",
+			storage: `<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">go</ac:parameter>` +
+				`<ac:plain-text-body><![CDATA[fmt.Println("<hi>")]]></ac:plain-text-body></ac:structured-macro>`,
+			want: "```go\nfmt.Println(\"<hi>\")\n```",
+		},
+		{
+			name: "expand",
+			storage: `<ac:structured-macro ac:name="expand"><ac:parameter ac:name="title">More</ac:parameter>` +
+				`<ac:rich-text-body><p>Hidden <strong>text</strong></p></ac:rich-text-body></ac:structured-macro>`,
+			want: "<details>\n<summary>More</summary>\n\nHidden **text**\n\n</details>",
+		},
+		{
+			name: "inline status",
+			storage: `<p>State: <ac:structured-macro ac:name="status"><ac:parameter ac:name="title">DONE</ac:parameter>` +
+				`</ac:structured-macro> now</p>`,
+			want: "State: **DONE** now",
+		},
+		{
+			name:    "self-closing toc",
+			storage: `<ac:structured-macro ac:name="toc" ac:schema-version="1"/><h1>Intro</h1><h2>Setup</h2><h2>Setup</h2>`,
+			want:    "- [Intro](#intro)\n  - [Setup](#setup)\n  - [Setup](#setup-1)\n\n# Intro\n\n## Setup\n\n## Setup",
+		},
+		{
+			name:    "children",
+			storage: `<ac:structured-macro ac:name="children" /><p>After</p>`,
+			opts:    Options{Index: testIndex(), Page: homePage},
+			want:    "- [Setup Guide](Setup_Guide.md)\n\nAfter",
+		},
+		{
+			name:    "anchor",
+			storage: `<p>a<ac:structured-macro ac:name="anchor"><ac:parameter ac:name="">top</ac:parameter></ac:structured-macro></p>`,
+			want:    "a<a id=\"top\"></a>",
+		},
+		{
+			name:    "jira issue",
+			storage: `<p><ac:structured-macro ac:name="jira"><ac:parameter ac:name="key">ABC-1</ac:parameter></ac:structured-macro></p>`,
+			want:    "ABC-1",
+		},
+		{
+			name:    "unknown macro keeps its body",
+			storage: `<ac:structured-macro ac:name="mystery"><ac:rich-text-body><p>kept</p></ac:rich-text-body></ac:structured-macro>`,
+			want:    "kept",
+		},
+	})
+}
+
+// admonition returns a panel macro with an optional title
+func admonition(name, title, body string) string {
+	var param string
+	if title != "" {
+		param = `<ac:parameter ac:name="title">` + title + `</ac:parameter>`
+	}
+	return `<ac:structured-macro ac:name="` + name + `">` + param +
+		`<ac:rich-text-body>` + body + `</ac:rich-text-body></ac:structured-macro>`
+}
+
+func TestConvertAdmonitions(t *testing.T) {
+	flavors := []struct {
+		flavor string
+		format string
+		kinds  []string
+	}{
+		{"blockquote", "> **%s:** Mind *this*", []string{"INFO", "TIP", "NOTE", "WARNING"}},
+		{"github", "> [!%s]\n> Mind *this*", []string{"NOTE", "TIP", "WARNING", "CAUTION"}},
+		{"mkdocs", "!!! %s\n    Mind *this*", []string{"info", "tip", "warning", "danger"}},
+		{"docusaurus", ":::%s\n\nMind *this*\n\n:::", []string{"info", "tip", "warning", "danger"}},
+	}
+	var tests []conversionTest
+	for _, f := range flavors {
+		for i, name := range []string{"info", "tip", "note", "warning"} {
+			tests = append(tests, conversionTest{
+				name:    f.flavor + " " + name,
+				storage: admonition(name, "", "<p>Mind <em>this</em></p>"),
+				opts:    Options{AdmonitionFlavor: f.flavor},
+				want:    fmt.Sprintf(f.format, f.kinds[i]),
+			})
+		}
+	}
+	runConversionTests(t, tests)
+
+	body := "<p>First</p><ul><li>one</li></ul>"
+	runConversionTests(t, []conversionTest{
+		{
+			name:    "default flavor",
+			storage: admonition("warning", "", "<p>Careful</p>"),
+			want:    "> **WARNING:** Careful",
+		},
+		{
+			name:    "blockquote with title",
+			storage: admonition("info", "Read *me*", body),
+			opts:    Options{AdmonitionFlavor: "blockquote"},
+			want:    "> **INFO:** Read \\*me\\*\n>\n> First\n>\n> - one",
+		},
+		{
+			name:    "blockquote with several blocks",
+			storage: admonition("info", "", body),
+			opts:    Options{AdmonitionFlavor: "blockquote"},
+			want:    "> **INFO:**\n>\n> First\n>\n> - one",
+		},
+		{
+			name:    "github with title",
+			storage: admonition("tip", "Hint", body),
+			opts:    Options{AdmonitionFlavor: "github"},
+			want:    "> [!TIP]\n> **Hint**\n>\n> First\n>\n> - one",
+		},
+		{
+			name:    "mkdocs with title",
+			storage: admonition("note", `Say "hi"`, body),
+			opts:    Options{AdmonitionFlavor: "mkdocs"},
+			want:    "!!! warning \"Say 'hi'\"\n    First\n\n    - one",
+		},
+		{
+			name:    "docusaurus with title",
+			storage: admonition("warning", "Stop", body),
+			opts:    Options{AdmonitionFlavor: "docusaurus"},
+			want:    ":::danger[Stop]\n\nFirst\n\n- one\n\n:::",
+		},
+		{
+			name:    "empty panel",
+			storage: admonition("info", "", ""),
+			opts:    Options{AdmonitionFlavor: "github"},
+			want:    "",
+		},
+		{
+			name:    "followed by a paragraph",
+			storage: admonition("tip", "", "<p>Hint</p>") + "<p>After</p>",
+			opts:    Options{AdmonitionFlavor: "docusaurus"},
+			want:    ":::tip\n\nHint\n\n:::\n\nAfter",
+		},
+	})
+}
@@ -0,0 +1,261 @@
+package converter
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// tableCell is a th or td element of a table
+type tableCell struct {
+	node    *goquery.Selection
+	header  bool
+	colspan int
+	rowspan int
+}
+
+// tableRows returns the cells of every row of table, skipping the rows of
+// nested tables
+func tableRows(table *goquery.Selection) [][]tableCell {
+	var rows [][]tableCell
+	table.Find("tr").Each(func(i int, tr *goquery.Selection) {
+		if !tr.Closest("table").IsSelection(table) {
+			return
+		}
+
+		var row []tableCell
+		tr.ChildrenFiltered("th, td").Each(func(j int, cell *goquery.Selection) {
+			row = append(row, tableCell{
+				node:    cell,
+				header:  goquery.NodeName(cell) == "th" || tr.ParentFiltered("thead").Length() > 0,
+				colspan: spanAttr(cell, "colspan"),
+				rowspan: spanAttr(cell, "rowspan"),
+			})
+		})
+		rows = append(rows, row)
+	})
+	return rows
+}
+
+// spanAttr returns the colspan or rowspan of a cell, at least 1
+func spanAttr(cell *goquery.Selection, name string) int {
+	value, _ := cell.Attr(name)
+	span, err := strconv.Atoi(value)
+	if err != nil || span < 1 {
+		return 1
+	}
+	return span
+}
+
+// isHeaderRow reports whether every cell of row is a header cell
+func isHeaderRow(row []tableCell) bool {
+	for _, cell := range row {
+		if !cell.header {
+			return false
+		}
+	}
+	return len(row) > 0
+}
+
+// isComplexTable reports whether rows can't be represented as a Markdown
+// table without losing structure
+func isComplexTable(rows [][]tableCell) bool {
+	for _, row := range rows {
+		for _, cell := range row {
+			if cell.colspan > 1 || cell.rowspan > 1 {
+				return true
+			}
+			if cell.node.Find("ul, ol, table, pre, blockquote, h1, h2, h3, h4, h5, h6, ac\\:structured-macro, ac\\:task-list").Length() > 0 ||
+				cell.node.Find("p").Length() > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// processTable converts HTML tables to Markdown tables. With HTMLTables,
+// tables that don't fit into Markdown are written as HTML.
+func (c *converter) processTable(table *goquery.Selection, markdown *strings.Builder) {
+	rows := tableRows(table)
+	if len(rows) == 0 {
+		return
+	}
+
+	if c.opts.HTMLTables && isComplexTable(rows) {
+		c.writeHTMLTable(rows, markdown)
+		return
+	}
+	c.writeMarkdownTable(rows, markdown)
+}
+
+// writeMarkdownTable writes rows as a Markdown table. Merged cells are
+// expanded into empty cells so the columns stay aligned, and short rows are
+// padded to the full width.
+func (c *converter) writeMarkdownTable(rows [][]tableCell, markdown *strings.Builder) {
+	// A Markdown table needs a header row, use an empty one if there is none
+	hasHeader := isHeaderRow(rows[0])
+
+	var grid [][]string
+	var spans []int // remaining rows covered by a rowspan, by column
+	for r, row := range rows {
+		var line []string
+		col := 0
+		skipSpanned := func() {
+			for col < len(spans) && spans[col] > 0 {
+				spans[col]--
+				line = append(line, "")
+				col++
+			}
+		}
+
+		for _, cell := range row {
+			skipSpanned()
+
+			text := c.cellText(cell.node)
+			// Header columns of body rows are kept apart from the data
+			if cell.header && (r > 0 || !hasHeader) && text != "" {
+				text = "**" + text + "**"
+			}
+
+			for k := 0; k < cell.colspan; k++ {
+				if k == 0 {
+					line = append(line, text)
+				} else {
+					line = append(line, "")
+				}
+				for len(spans) <= col {
+					spans = append(spans, 0)
+				}
+				spans[col] = cell.rowspan - 1
+				col++
+			}
+		}
+		skipSpanned()
+		grid = append(grid, line)
+	}
+
+	columns := 0
+	for _, line := range grid {
+		columns = max(columns, len(line))
+	}
+	if columns == 0 {
+		return
+	}
+	if !hasHeader {
+		grid = append([][]string{make([]string, columns)}, grid...)
+	}
+
+	// Pad short rows and align the columns
+	widths := make([]int, columns)
+	for i := range widths {
+		widths[i] = 3
+	}
+	for i, line := range grid {
+		for len(line) < columns {
+			line = append(line, "")
+		}
+		grid[i] = line
+		for j, text := range line {
+			widths[j] = max(widths[j], utf8.RuneCountInString(text))
+		}
+	}
+
+	writeRow := func(line []string) {
+		markdown.WriteString("|")
+		for j, text := range line {
+			markdown.WriteString(" " + text + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(text)) + " |")
+		}
+		markdown.WriteString("\n")
+	}
+
+	writeRow(grid[0])
+	separator := make([]string, columns)
+	for j := range separator {
+		separator[j] = strings.Repeat("-", widths[j])
+	}
+	writeRow(separator)
+	for _, line := range grid[1:] {
+		writeRow(line)
+	}
+	markdown.WriteString("\n")
+}
+
+// writeHTMLTable writes rows as an HTML table. Cell content is converted to
+// Markdown and separated by blank lines, so Markdown renderers process it.
+func (c *converter) writeHTMLTable(rows [][]tableCell, markdown *strings.Builder) {
+	markdown.WriteString("<table>\n")
+	for _, row := range rows {
+		markdown.WriteString("<tr>\n")
+		for _, cell := range row {
+			tag := "td"
+			if cell.header {
+				tag = "th"
+			}
+
+			markdown.WriteString("<" + tag)
+			if cell.colspan > 1 {
+				markdown.WriteString(" colspan=\"" + strconv.Itoa(cell.colspan) + "\"")
+			}
+			if cell.rowspan > 1 {
+				markdown.WriteString(" rowspan=\"" + strconv.Itoa(cell.rowspan) + "\"")
+			}
+			markdown.WriteString(">")
+
+			var content strings.Builder
+			c.processBlocks(cell.node, &content)
+			if text := cleanupMarkdown(content.String()); text != "" {
+				markdown.WriteString("\n\n" + text + "\n\n")
+			}
+			markdown.WriteString("</" + tag + ">\n")
+		}
+		markdown.WriteString("</tr>\n")
+	}
+	markdown.WriteString("</table>\n\n")
+}
+
+// cellText renders the content of a table cell on a single line. Paragraphs,
+// list items and line breaks are separated by <br> and pipes are escaped.
+func (c *converter) cellText(cell *goquery.Selection) string {
+	var parts []string
+	var current strings.Builder
+	flush := func() {
+		if text := cellLine(current.String()); text != "" {
+			parts = append(parts, text)
+		}
+		current.Reset()
+	}
+
+	cell.Contents().Each(func(i int, child *goquery.Selection) {
+		switch goquery.NodeName(child) {
+		case "p", "div", "h1", "h2", "h3", "h4", "h5", "h6", "pre", "blockquote":
+			flush()
+			current.WriteString(c.inline(child))
+			flush()
+		case "ul", "ol":
+			flush()
+			child.ChildrenFiltered("li").Each(func(j int, li *goquery.Selection) {
+				marker := "- "
+				if goquery.NodeName(child) == "ol" {
+					marker = strconv.Itoa(j+1) + ". "
+				}
+				if text := cellLine(c.inline(li)); text != "" {
+					parts = append(parts, marker+text)
+				}
+			})
+		default:
+			c.inlineNode(child, &current)
+		}
+	})
+	flush()
+
+	return strings.ReplaceAll(strings.Join(parts, "<br>"), "|", "\\|")
+}
+
+// cellLine collapses inline Markdown to one line, keeping hard line breaks as <br>
+func cellLine(text string) string {
+	text = strings.ReplaceAll(text, "  \n", "<br>")
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -2,53 +2,250 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	_ "github.com/marcboeker/go-duckdb"
 )
 
+// Page is a page with its metadata, labels, attachments and outgoing links
 type Page struct {
-	Title string
-	Body  string
-	Link  string
-	UID   string
+	UID       string
+	SpaceKey  string
+	SpaceName string
+	ParentID  string
+	Title     string
+	Version   int
+	Markdown  string
+	Storage   string
+	Link      string
+	CreatedBy string
+	CreatedAt string
+	UpdatedBy string
+	UpdatedAt string
+
+	Labels      []Label
+	Attachments []Attachment
+	Links       []PageLink
+}
+
+// Label is a label of a page
+type Label struct {
+	ID   string
+	Name string
+}
+
+// Attachment is a file attached to a page
+type Attachment struct {
+	ID          string
+	FileName    string
+	MediaType   string
+	FileSize    int64
+	DownloadURL string
+}
+
+// PageLink is a link from a page to another page or an external URL
+type PageLink struct {
+	TargetUID      string
+	TargetSpaceKey string
+	TargetTitle    string
+	URL            string
+}
+
+// PageVersion is a historical version of a page
+type PageVersion struct {
+	PageUID   string
+	Version   int
+	Title     string
+	Author    string
+	CreatedAt string
+	Message   string
+	Storage   string
+	Markdown  string
+}
+
+// Chunk is a part of a page's Markdown
+type Chunk struct {
+	PageUID    string
+	Index      int
+	SpaceKey   string
+	Title      string
+	Breadcrumb []string
+	Headings   []string
+	Link       string
+	Text       string
+	Tokens     int
+	Characters int
+	// Embedding is the vector of Text computed by EmbeddingModel, if any
+	Embedding      []float32
+	EmbeddingModel string
 }
 
-// InitDB initializes the DuckDB database and creates the pages table if it doesn't exist
+// InitDB initializes the DuckDB database and migrates it to the current schema
 func InitDB(dbPath string) (*sql.DB, error) {
 	db, err := sql.Open("duckdb", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
-	// Create table if it doesn't exist
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS pages (
-			uid VARCHAR PRIMARY KEY,
-			title VARCHAR,
-			body VARCHAR,
-			link VARCHAR
-		)
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create table: %v", err)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
 	}
 
 	return db, nil
 }
 
-// InsertPage inserts a page into the database or updates it if it already exists
+// InsertPage inserts a page into the database or updates it if it already
+// exists. The page's labels, attachments and links replace the previous ones.
 func InsertPage(db *sql.DB, page Page) error {
-	_, err := db.Exec(`
-		INSERT OR REPLACE INTO pages (uid, title, body, link)
-		VALUES (?, ?, ?, ?)
-	`, page.UID, page.Title, page.Body, page.Link)
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to insert/update page: %v", err)
+	}
+	defer tx.Rollback()
 
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO pages (uid, space_key, parent_id, title, version, markdown, storage, link, created_by, created_at, updated_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, page.UID, nullString(page.SpaceKey), nullString(page.ParentID), page.Title, page.Version, page.Markdown, page.Storage, page.Link,
+		page.CreatedBy, timestamp(page.CreatedAt), page.UpdatedBy, timestamp(page.UpdatedAt))
 	if err != nil {
 		return fmt.Errorf("failed to insert/update page: %v", err)
 	}
 
+	if page.SpaceKey != "" {
+		// Keep a known space name if the page doesn't have one
+		_, err = tx.Exec(`
+			INSERT INTO spaces (key, name) VALUES (?, ?)
+			ON CONFLICT (key) DO UPDATE SET name = coalesce(excluded.name, name)
+		`, page.SpaceKey, nullString(page.SpaceName))
+		if err != nil {
+			return fmt.Errorf("failed to insert/update space: %v", err)
+		}
+	}
+
+	// Edges of the page are replaced as a whole
+	for _, table := range []string{"page_labels", "page_links"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE page_uid = ?`, page.UID); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", table, err)
+		}
+	}
+
+	for _, label := range page.Labels {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO labels (id, name) VALUES (?, ?)`, label.ID, label.Name); err != nil {
+			return fmt.Errorf("failed to insert/update label: %v", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO page_labels (page_uid, label_id) VALUES (?, ?)`, page.UID, label.ID); err != nil {
+			return fmt.Errorf("failed to insert page label: %v", err)
+		}
+	}
+
+	for _, link := range page.Links {
+		_, err := tx.Exec(`
+			INSERT INTO page_links (page_uid, target_uid, target_space_key, target_title, url)
+			VALUES (?, ?, ?, ?, ?)
+		`, page.UID, nullString(link.TargetUID), nullString(link.TargetSpaceKey), nullString(link.TargetTitle), nullString(link.URL))
+		if err != nil {
+			return fmt.Errorf("failed to insert page link: %v", err)
+		}
+	}
+
+	// Attachments are only known if they were included in the export
+	if len(page.Attachments) > 0 {
+		args := []any{page.UID}
+		for _, attachment := range page.Attachments {
+			_, err := tx.Exec(`
+				INSERT OR REPLACE INTO attachments (id, page_uid, file_name, media_type, file_size, download_url)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, attachment.ID, page.UID, attachment.FileName, attachment.MediaType, attachment.FileSize, attachment.DownloadURL)
+			if err != nil {
+				return fmt.Errorf("failed to insert/update attachment: %v", err)
+			}
+			args = append(args, attachment.ID)
+		}
+
+		// Remove attachments that were deleted from the page
+		query := `DELETE FROM attachments WHERE page_uid = ? AND id NOT IN (?` + strings.Repeat(", ?", len(args)-2) + `)`
+		if _, err := tx.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to delete attachments: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert/update page: %v", err)
+	}
+	return nil
+}
+
+// InsertPageVersion inserts a historical version of a page or updates it if
+// it already exists
+func InsertPageVersion(db *sql.DB, version PageVersion) error {
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO page_versions (page_uid, version, title, author, created_at, message, storage, markdown)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, version.PageUID, version.Version, version.Title, version.Author, timestamp(version.CreatedAt), version.Message, version.Storage, version.Markdown)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert/update page version: %v", err)
+	}
+
+	return nil
+}
+
+// ReplaceChunks replaces the chunks of a page
+func ReplaceChunks(db *sql.DB, pageUID string, chunks []Chunk) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to replace chunks: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE page_uid = ?`, pageUID); err != nil {
+		return fmt.Errorf("failed to delete chunks: %v", err)
+	}
+	for _, chunk := range chunks {
+		// Lists can't be passed as parameters, they are passed as JSON instead
+		_, err := tx.Exec(`
+			INSERT INTO chunks (page_uid, chunk_index, space_key, title, breadcrumb, headings, link, text, tokens, characters, embedding, embedding_model)
+			VALUES (?, ?, ?, ?, ?::JSON::VARCHAR[], ?::JSON::VARCHAR[], ?, ?, ?, ?, ?::JSON::FLOAT[], ?)
+		`, pageUID, chunk.Index, nullString(chunk.SpaceKey), chunk.Title, jsonList(chunk.Breadcrumb), jsonList(chunk.Headings),
+			chunk.Link, chunk.Text, chunk.Tokens, chunk.Characters, jsonVector(chunk.Embedding), nullString(chunk.EmbeddingModel))
+		if err != nil {
+			return fmt.Errorf("failed to insert chunk: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to replace chunks: %v", err)
+	}
+	return nil
+}
+
+// DeletePage removes a page with its versions, labels, attachments, links and
+// chunks from the database
+func DeletePage(db *sql.DB, uid string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to delete page: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM pages WHERE uid = ?`, uid); err != nil {
+		return fmt.Errorf("failed to delete page: %v", err)
+	}
+	for _, table := range []string{"page_versions", "page_labels", "page_links", "attachments", "chunks"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE page_uid = ?`, uid); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete page: %v", err)
+	}
 	return nil
 }
 
@@ -59,4 +256,39 @@ func CloseDB(db *sql.DB) {
 			log.Printf("Error closing database: %v", err)
 		}
 	}
-} 
\ No newline at end of file
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// timestamp parses a Confluence timestamp, or returns NULL if it is empty or invalid
+func timestamp(s string) any {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// jsonList encodes a list of strings as JSON array
+func jsonList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// jsonVector encodes a vector as JSON array, or returns NULL if it is empty
+func jsonVector(vector []float32) any {
+	if len(vector) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(vector)
+	return string(data)
+}
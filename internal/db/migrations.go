@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations upgrade the schema step by step. Migration i brings the schema
+// to version i+1; applied versions are recorded in schema_migrations, so
+// existing databases are upgraded in place. Never change a released
+// migration, append a new one instead.
+var migrations = []string{
+	// 1: the original pages table and page versions
+	`
+	CREATE TABLE IF NOT EXISTS pages (
+		uid VARCHAR PRIMARY KEY,
+		title VARCHAR,
+		body VARCHAR,
+		link VARCHAR
+	);
+	CREATE TABLE IF NOT EXISTS page_versions (
+		page_uid VARCHAR,
+		version INTEGER,
+		title VARCHAR,
+		author VARCHAR,
+		created_at VARCHAR,
+		message VARCHAR,
+		storage VARCHAR,
+		body VARCHAR,
+		PRIMARY KEY (page_uid, version)
+	);
+	`,
+	// 2: page metadata, spaces, labels, attachments and links between pages
+	`
+	ALTER TABLE pages RENAME COLUMN body TO markdown;
+	ALTER TABLE pages ADD COLUMN space_key VARCHAR;
+	ALTER TABLE pages ADD COLUMN parent_id VARCHAR;
+	ALTER TABLE pages ADD COLUMN version INTEGER;
+	ALTER TABLE pages ADD COLUMN storage VARCHAR;
+	ALTER TABLE pages ADD COLUMN created_by VARCHAR;
+	ALTER TABLE pages ADD COLUMN created_at TIMESTAMP;
+	ALTER TABLE pages ADD COLUMN updated_by VARCHAR;
+	ALTER TABLE pages ADD COLUMN updated_at TIMESTAMP;
+	ALTER TABLE page_versions RENAME COLUMN body TO markdown;
+	ALTER TABLE page_versions ALTER COLUMN created_at TYPE TIMESTAMP USING TRY_CAST(created_at AS TIMESTAMP);
+	CREATE TABLE spaces (
+		key VARCHAR PRIMARY KEY,
+		name VARCHAR
+	);
+	CREATE TABLE labels (
+		id VARCHAR PRIMARY KEY,
+		name VARCHAR
+	);
+	CREATE TABLE page_labels (
+		page_uid VARCHAR,
+		label_id VARCHAR
+	);
+	CREATE TABLE attachments (
+		id VARCHAR PRIMARY KEY,
+		page_uid VARCHAR,
+		file_name VARCHAR,
+		media_type VARCHAR,
+		file_size BIGINT,
+		download_url VARCHAR
+	);
+	CREATE TABLE page_links (
+		page_uid VARCHAR,
+		target_uid VARCHAR,
+		target_space_key VARCHAR,
+		target_title VARCHAR,
+		url VARCHAR
+	);
+	`,
+	// 3: chunks of pages for retrieval pipelines
+	`
+	CREATE TABLE chunks (
+		page_uid VARCHAR,
+		chunk_index INTEGER,
+		space_key VARCHAR,
+		title VARCHAR,
+		breadcrumb VARCHAR[],
+		headings VARCHAR[],
+		link VARCHAR,
+		text VARCHAR,
+		tokens INTEGER,
+		characters INTEGER
+	);
+	`,
+	// 4: embedding vectors of chunks
+	`
+	ALTER TABLE chunks ADD COLUMN embedding FLOAT[];
+	ALTER TABLE chunks ADD COLUMN embedding_model VARCHAR;
+	`,
+}
+
+// migrate applies the migrations that are missing in the database
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at TIMESTAMP DEFAULT current_timestamp
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", current, len(migrations))
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate schema to version %d: %v", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record schema version %d: %v", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to migrate schema to version %d: %v", version, err)
+		}
+	}
+
+	return nil
+}
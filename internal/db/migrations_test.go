@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openBaselineDB creates a database with the schema written before migrations
+// were introduced, holding a single page
+func openBaselineDB(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "baseline.db")
+	conn, err := sql.Open("duckdb", path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Exec(`
+		CREATE TABLE pages (
+			uid VARCHAR PRIMARY KEY,
+			title VARCHAR,
+			body VARCHAR,
+			link VARCHAR
+		);
+		INSERT INTO pages VALUES ('1', 'Home', '# Home', 'https://example.atlassian.net/wiki/spaces/TEAM/pages/1');
+	`)
+	if err != nil {
+		t.Fatalf("failed to create baseline schema: %v", err)
+	}
+	return path
+}
+
+// appliedMigrations returns the versions and times recorded in schema_migrations
+func appliedMigrations(t *testing.T, conn *sql.DB) []string {
+	rows, err := conn.Query(`SELECT version, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatalf("failed to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	var applied []string
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			t.Fatal(err)
+		}
+		applied = append(applied, fmt.Sprintf("%d@%s", version, appliedAt))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return applied
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	path := openBaselineDB(t)
+
+	conn, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	applied := appliedMigrations(t, conn)
+	if len(applied) != len(migrations) {
+		t.Fatalf("schema_migrations = %v, want %d versions", applied, len(migrations))
+	}
+	for i, entry := range applied {
+		if version, _, _ := strings.Cut(entry, "@"); version != fmt.Sprint(i+1) {
+			t.Errorf("schema_migrations has %s at position %d, want version %d", entry, i, i+1)
+		}
+	}
+
+	// The existing page survives with its body in the renamed column
+	var title, markdown string
+	if err := conn.QueryRow(`SELECT title, markdown FROM pages WHERE uid = '1'`).Scan(&title, &markdown); err != nil {
+		t.Fatalf("failed to read migrated page: %v", err)
+	}
+	if title != "Home" || markdown != "# Home" {
+		t.Errorf("migrated page = %q, %q, want Home, # Home", title, markdown)
+	}
+	for _, query := range []string{
+		`SELECT space_key, parent_id, version, storage, updated_at FROM pages`,
+		`SELECT page_uid, version, markdown, created_at FROM page_versions`,
+		`SELECT key, name FROM spaces`,
+		`SELECT page_uid, label_id FROM page_labels`,
+		`SELECT id, file_name, download_url FROM attachments`,
+		`SELECT target_uid, url FROM page_links`,
+		`SELECT chunk_index, text, embedding, embedding_model FROM chunks`,
+	} {
+		if _, err := conn.Exec(query); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+	if err := InsertPage(conn, Page{UID: "2", Title: "Guide", Markdown: "# Guide", SpaceKey: "TEAM"}); err != nil {
+		t.Errorf("InsertPage after migrating: %v", err)
+	}
+	CloseDB(conn)
+
+	// A second run finds every migration applied and changes nothing
+	conn, err = InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB again: %v", err)
+	}
+	defer CloseDB(conn)
+	if again := appliedMigrations(t, conn); strings.Join(again, ",") != strings.Join(applied, ",") {
+		t.Errorf("schema_migrations after a second run = %v, want %v", again, applied)
+	}
+	var pages int
+	if err := conn.QueryRow(`SELECT count(*) FROM pages`).Scan(&pages); err != nil || pages != 2 {
+		t.Errorf("pages after a second run = %d, %v, want 2", pages, err)
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "newer.db")
+	conn, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	if _, err := conn.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, len(migrations)+1); err != nil {
+		t.Fatal(err)
+	}
+	CloseDB(conn)
+
+	if conn, err := InitDB(path); err == nil || !strings.Contains(err.Error(), "newer than the supported version") {
+		CloseDB(conn)
+		t.Errorf("InitDB = %v, want an error about the newer schema", err)
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SearchResult is a page matching a full-text search
+type SearchResult struct {
+	UID      string
+	SpaceKey string
+	Title    string
+	Link     string
+	Score    float64
+}
+
+// CreateSearchIndex builds the full-text index over the title and Markdown of
+// all pages with DuckDB's fts extension, replacing an existing index. The
+// index doesn't follow later changes, so it is rebuilt after every export.
+func CreateSearchIndex(db *sql.DB) error {
+	if _, err := db.Exec(`INSTALL fts; LOAD fts`); err != nil {
+		return fmt.Errorf("failed to load the fts extension: %v", err)
+	}
+	if _, err := db.Exec(`PRAGMA create_fts_index('pages', 'uid', 'title', 'markdown', overwrite = 1)`); err != nil {
+		return fmt.Errorf("failed to create full-text index: %v", err)
+	}
+	return nil
+}
+
+// OpenDB opens an existing database read-only, for queries on an export
+func OpenDB(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("duckdb", dbPath+"?access_mode=read_only")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return db, nil
+}
+
+// Search returns up to limit pages matching query, best match (by BM25) first
+func Search(db *sql.DB, query string, limit int) ([]SearchResult, error) {
+	if _, err := db.Exec(`LOAD fts`); err != nil {
+		return nil, fmt.Errorf("failed to load the fts extension: %v", err)
+	}
+
+	// match_bm25 is a macro, pass the query as a literal instead of a parameter
+	literal := "'" + strings.ReplaceAll(query, "'", "''") + "'"
+	rows, err := db.Query(`
+		SELECT uid, coalesce(space_key, ''), title, coalesce(link, ''), score
+		FROM (
+			SELECT *, fts_main_pages.match_bm25(uid, `+literal+`) AS score
+			FROM pages
+		)
+		WHERE score IS NOT NULL
+		ORDER BY score DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		if strings.Contains(err.Error(), "fts_main_pages") {
+			return nil, fmt.Errorf("the database has no full-text index, run an export with the db output first: %v", err)
+		}
+		return nil, fmt.Errorf("failed to search pages: %v", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.UID, &r.SpaceKey, &r.Title, &r.Link, &r.Score); err != nil {
+			return nil, fmt.Errorf("failed to read search results: %v", err)
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
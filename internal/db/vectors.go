@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SimilarChunk is a chunk close to a query vector
+type SimilarChunk struct {
+	PageUID    string
+	ChunkIndex int
+	Title      string
+	Headings   []string
+	Link       string
+	Text       string
+	// Similarity is the cosine similarity to the query, 1 for the same direction
+	Similarity float64
+}
+
+// CreateVectorIndex copies the embeddings of all chunks into the
+// chunk_vectors table, as fixed-size arrays with an HNSW index of DuckDB's vss
+// extension. Like the full-text index, it is rebuilt after every export.
+// Without the index, SimilarChunks compares the query with every chunk.
+func CreateVectorIndex(db *sql.DB) error {
+	// A stale index would miss changed chunks, so drop it first
+	if _, err := db.Exec(`DROP TABLE IF EXISTS chunk_vectors`); err != nil {
+		return fmt.Errorf("failed to drop vector index: %v", err)
+	}
+
+	var minDims, maxDims sql.NullInt64
+	err := db.QueryRow(`SELECT min(len(embedding)), max(len(embedding)) FROM chunks WHERE embedding IS NOT NULL`).Scan(&minDims, &maxDims)
+	if err != nil {
+		return fmt.Errorf("failed to read embedding dimensions: %v", err)
+	}
+	if !maxDims.Valid {
+		return nil
+	}
+	if minDims.Int64 != maxDims.Int64 {
+		return fmt.Errorf("embeddings have between %d and %d dimensions, export again with a single embedding model", minDims.Int64, maxDims.Int64)
+	}
+
+	if _, err := db.Exec(`INSTALL vss; LOAD vss; SET hnsw_enable_experimental_persistence = true`); err != nil {
+		return fmt.Errorf("failed to load the vss extension: %v", err)
+	}
+	_, err = db.Exec(fmt.Sprintf(`
+		CREATE TABLE chunk_vectors AS
+		SELECT page_uid, chunk_index, embedding::FLOAT[%d] AS embedding
+		FROM chunks
+		WHERE embedding IS NOT NULL;
+		CREATE INDEX chunk_vectors_hnsw ON chunk_vectors USING HNSW (embedding) WITH (metric = 'cosine');
+	`, maxDims.Int64))
+	if err != nil {
+		db.Exec(`DROP TABLE IF EXISTS chunk_vectors`)
+		return fmt.Errorf("failed to create vector index: %v", err)
+	}
+	return nil
+}
+
+// SimilarChunks returns up to limit chunks whose embeddings are closest to
+// vector, most similar first. model must be the model the chunks were
+// embedded with.
+func SimilarChunks(db *sql.DB, vector []float32, model string, limit int) ([]SimilarChunk, error) {
+	rows, err := db.Query(`SELECT DISTINCT embedding_model FROM chunks WHERE embedding IS NOT NULL`)
+	if err != nil {
+		if strings.Contains(err.Error(), "chunks") {
+			return nil, fmt.Errorf("the database has no chunks, run an export with the chunks output and the duckdb format first: %v", err)
+		}
+		return nil, fmt.Errorf("failed to read embedding models: %v", err)
+	}
+	var models []string
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read embedding models: %v", err)
+		}
+		models = append(models, m)
+	}
+	rows.Close()
+
+	switch {
+	case len(models) == 0:
+		return nil, fmt.Errorf("the chunks have no embeddings, run an export with an embedding provider first")
+	case len(models) > 1 || models[0] != model:
+		return nil, fmt.Errorf("the chunks were embedded with %s, not with %s", strings.Join(models, ", "), model)
+	}
+
+	query := jsonVector(vector)
+	var nearest string
+	if useVectorIndex(db) {
+		// Ordering chunk_vectors by distance with a limit is answered by the HNSW index
+		nearest = fmt.Sprintf(`
+			SELECT page_uid, chunk_index, array_cosine_distance(embedding, ?::JSON::FLOAT[]::FLOAT[%d]) AS distance
+			FROM chunk_vectors
+			ORDER BY distance
+			LIMIT ?
+		`, len(vector))
+	} else {
+		nearest = `
+			SELECT page_uid, chunk_index, 1 - list_cosine_similarity(embedding, ?::JSON::FLOAT[]) AS distance
+			FROM chunks
+			WHERE embedding IS NOT NULL
+			ORDER BY distance
+			LIMIT ?
+		`
+	}
+
+	rows, err = db.Query(`
+		WITH nearest AS (`+nearest+`)
+		SELECT c.page_uid, c.chunk_index, c.title, c.headings, coalesce(c.link, ''), c.text, 1 - n.distance
+		FROM nearest n
+		JOIN chunks c USING (page_uid, chunk_index)
+		ORDER BY n.distance
+	`, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query similar chunks: %v", err)
+	}
+	defer rows.Close()
+
+	var results []SimilarChunk
+	for rows.Next() {
+		var r SimilarChunk
+		var headings []any
+		if err := rows.Scan(&r.PageUID, &r.ChunkIndex, &r.Title, &headings, &r.Link, &r.Text, &r.Similarity); err != nil {
+			return nil, fmt.Errorf("failed to read similar chunks: %v", err)
+		}
+		for _, heading := range headings {
+			if s, ok := heading.(string); ok {
+				r.Headings = append(r.Headings, s)
+			}
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// useVectorIndex reports whether the HNSW index of chunk_vectors can be used
+func useVectorIndex(db *sql.DB) bool {
+	var exists bool
+	err := db.QueryRow(`SELECT count(*) > 0 FROM duckdb_tables() WHERE table_name = 'chunk_vectors'`).Scan(&exists)
+	if err != nil || !exists {
+		return false
+	}
+	_, err = db.Exec(`LOAD vss`)
+	return err == nil
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestDB(t *testing.T, chunks ...Chunk) *sql.DB {
+	conn, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { CloseDB(conn) })
+
+	byPage := make(map[string][]Chunk)
+	for _, chunk := range chunks {
+		byPage[chunk.PageUID] = append(byPage[chunk.PageUID], chunk)
+	}
+	for page, pageChunks := range byPage {
+		if err := ReplaceChunks(conn, page, pageChunks); err != nil {
+			t.Fatalf("ReplaceChunks: %v", err)
+		}
+	}
+	return conn
+}
+
+func TestSimilarChunksRanksByCosineSimilarity(t *testing.T) {
+	conn := newTestDB(t,
+		Chunk{PageUID: "1", Index: 0, Title: "Deploy", Headings: []string{"Rollback"}, Text: "roll back", Embedding: []float32{1, 0, 0}, EmbeddingModel: "m"},
+		Chunk{PageUID: "1", Index: 1, Title: "Deploy", Text: "release", Embedding: []float32{0.6, 0.8, 0}, EmbeddingModel: "m"},
+		Chunk{PageUID: "2", Index: 0, Title: "Budget", Text: "money", Embedding: []float32{0, 0, 1}, EmbeddingModel: "m"},
+		Chunk{PageUID: "3", Index: 0, Title: "Not embedded", Text: "text"},
+	)
+
+	results, err := SimilarChunks(conn, []float32{1, 0, 0}, "m", 2)
+	if err != nil {
+		t.Fatalf("SimilarChunks: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	first, second := results[0], results[1]
+	if first.PageUID != "1" || first.ChunkIndex != 0 || first.Text != "roll back" || strings.Join(first.Headings, "/") != "Rollback" {
+		t.Errorf("first result = %+v", first)
+	}
+	if first.Similarity < 0.999 {
+		t.Errorf("similarity of the same vector = %f, want 1", first.Similarity)
+	}
+	if second.PageUID != "1" || second.ChunkIndex != 1 || second.Similarity < 0.599 || second.Similarity > 0.601 {
+		t.Errorf("second result = %+v, want chunk 1-1 with similarity 0.6", second)
+	}
+}
+
+func TestSimilarChunksChecksEmbeddingModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunks  []Chunk
+		wantErr string
+	}{
+		{
+			name:    "no embeddings",
+			chunks:  []Chunk{{PageUID: "1", Text: "text"}},
+			wantErr: "the chunks have no embeddings",
+		},
+		{
+			name:    "other model",
+			chunks:  []Chunk{{PageUID: "1", Text: "text", Embedding: []float32{1}, EmbeddingModel: "hash-256"}},
+			wantErr: "the chunks were embedded with hash-256, not with m",
+		},
+		{
+			name: "mixed models",
+			chunks: []Chunk{
+				{PageUID: "1", Text: "text", Embedding: []float32{1}, EmbeddingModel: "m"},
+				{PageUID: "2", Text: "text", Embedding: []float32{1}, EmbeddingModel: "n"},
+			},
+			wantErr: "the chunks were embedded with",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestDB(t, tt.chunks...)
+			_, err := SimilarChunks(conn, []float32{1}, "m", 10)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("SimilarChunks error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateVectorIndexRejectsMixedDimensions(t *testing.T) {
+	conn := newTestDB(t,
+		Chunk{PageUID: "1", Text: "a", Embedding: []float32{1, 0}, EmbeddingModel: "m"},
+		Chunk{PageUID: "2", Text: "b", Embedding: []float32{1, 0, 0}, EmbeddingModel: "m"},
+	)
+
+	err := CreateVectorIndex(conn)
+	if err == nil || !strings.Contains(err.Error(), "between 2 and 3 dimensions") {
+		t.Errorf("CreateVectorIndex error = %v", err)
+	}
+}
+
+func TestCreateVectorIndexWithoutEmbeddings(t *testing.T) {
+	conn := newTestDB(t, Chunk{PageUID: "1", Text: "a"})
+
+	if err := CreateVectorIndex(conn); err != nil {
+		t.Errorf("CreateVectorIndex: %v", err)
+	}
+	if useVectorIndex(conn) {
+		t.Errorf("a vector index was created without embeddings")
+	}
+}
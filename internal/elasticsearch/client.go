@@ -0,0 +1,148 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to the REST API of an Elasticsearch or OpenSearch cluster
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	// APIKey is sent as ApiKey authorization instead of basic authentication
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client for the cluster at baseURL
+func NewClient(baseURL, username, password, apiKey string) *Client {
+	return &Client{
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		Username: username,
+		Password: password,
+		APIKey:   apiKey,
+		HTTPClient: &http.Client{
+			Timeout: 120 * time.Second,
+		},
+	}
+}
+
+// Error is returned when the cluster answers with a non-2xx status code
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// BulkError is returned when some actions of a bulk request failed
+type BulkError struct {
+	Failed int
+	Total  int
+	// First describes the first failed action
+	First string
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d of %d bulk actions failed, first: %s", e.Failed, e.Total, e.First)
+}
+
+// PutIndexTemplate creates or replaces a composable index template
+func (c *Client) PutIndexTemplate(name string, template []byte) error {
+	_, err := c.do("PUT", "/_index_template/"+url.PathEscape(name), "application/json", template)
+	return err
+}
+
+// Bulk sends NDJSON bulk actions. It returns a BulkError if the cluster
+// rejected some of them.
+func (c *Client) Bulk(body []byte) error {
+	data, err := c.do("POST", "/_bulk", "application/x-ndjson", body)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Errors bool                         `json:"errors"`
+		Items  []map[string]json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %v", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+
+	bulkErr := &BulkError{Total: len(result.Items)}
+	for _, item := range result.Items {
+		// Every item has a single key, the action
+		for action, raw := range item {
+			var status struct {
+				ID    string          `json:"_id"`
+				Error json.RawMessage `json:"error"`
+			}
+			if json.Unmarshal(raw, &status) != nil || len(status.Error) == 0 {
+				continue
+			}
+			if bulkErr.Failed++; bulkErr.First == "" {
+				bulkErr.First = fmt.Sprintf("%s %s: %s", action, status.ID, status.Error)
+			}
+		}
+	}
+	return bulkErr
+}
+
+// do sends a request and returns the body of the answer
+func (c *Client) do(method, path, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+c.APIKey)
+	} else if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &Error{Method: method, Path: path, StatusCode: resp.StatusCode}
+		// Both Elasticsearch and OpenSearch describe errors as {"error": {"reason": ...}}
+		var answer struct {
+			Error struct {
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &answer) == nil && answer.Error.Reason != "" {
+			e.Message = answer.Error.Reason
+		} else {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return nil, e
+	}
+	return data, nil
+}
@@ -0,0 +1,131 @@
+package elasticsearch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const bulkBody = `{"index":{"_index":"confluence","_id":"1"}}
+{"title":"Home"}
+{"delete":{"_index":"confluence","_id":"2"}}
+`
+
+func TestBulkRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		apiKey   string
+		wantAuth string
+	}{
+		{name: "without authentication"},
+		{name: "basic authentication", username: "elastic", password: "secret", wantAuth: "Basic ZWxhc3RpYzpzZWNyZXQ="},
+		{name: "API key", username: "elastic", password: "secret", apiKey: "a2V5", wantAuth: "ApiKey a2V5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path, contentType, auth, body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				method, path, body = r.Method, r.URL.Path, string(data)
+				contentType, auth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+				fmt.Fprint(w, `{"errors":false,"items":[]}`)
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL+"/", tt.username, tt.password, tt.apiKey)
+			if err := client.Bulk([]byte(bulkBody)); err != nil {
+				t.Fatalf("Bulk: %v", err)
+			}
+			if method != "POST" || path != "/_bulk" {
+				t.Errorf("request = %s %s, want POST /_bulk", method, path)
+			}
+			if contentType != "application/x-ndjson" {
+				t.Errorf("Content-Type = %q, want application/x-ndjson", contentType)
+			}
+			if body != bulkBody {
+				t.Errorf("body = %q, want %q", body, bulkBody)
+			}
+			if auth != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", auth, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestBulkErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		want     error
+	}{
+		{
+			name:     "failed actions",
+			status:   http.StatusOK,
+			response: `{"errors":true,"items":[{"index":{"_id":"1","status":201}},{"index":{"_id":"2","status":400,"error":{"type":"mapper_parsing_exception"}}},{"delete":{"_id":"3","status":404,"error":{"type":"not_found"}}}]}`,
+			want:     &BulkError{Failed: 2, Total: 3, First: `index 2: {"type":"mapper_parsing_exception"}`},
+		},
+		{
+			name:     "rejected request",
+			status:   http.StatusRequestEntityTooLarge,
+			response: `{"error":{"reason":"request too large"}}`,
+			want:     &Error{Method: "POST", Path: "/_bulk", StatusCode: http.StatusRequestEntityTooLarge, Message: "request too large"},
+		},
+		{
+			name:     "answer without reason",
+			status:   http.StatusBadGateway,
+			response: "upstream down\n",
+			want:     &Error{Method: "POST", Path: "/_bulk", StatusCode: http.StatusBadGateway, Message: "upstream down"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+
+			err := NewClient(server.URL, "", "", "").Bulk([]byte(bulkBody))
+			switch want := tt.want.(type) {
+			case *BulkError:
+				var got *BulkError
+				if !errors.As(err, &got) || *got != *want {
+					t.Errorf("Bulk = %v, want %v", err, want)
+				}
+			case *Error:
+				var got *Error
+				if !errors.As(err, &got) || *got != *want {
+					t.Errorf("Bulk = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPutIndexTemplate(t *testing.T) {
+	var method, path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.EscapedPath(), string(data)
+		contentType = r.Header.Get("Content-Type")
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	}))
+	defer server.Close()
+
+	template := `{"index_patterns":["wiki pages"]}`
+	if err := NewClient(server.URL, "", "", "").PutIndexTemplate("wiki pages", []byte(template)); err != nil {
+		t.Fatalf("PutIndexTemplate: %v", err)
+	}
+	if method != "PUT" || path != "/_index_template/wiki%20pages" {
+		t.Errorf("request = %s %s, want PUT /_index_template/wiki%%20pages", method, path)
+	}
+	if contentType != "application/json" || body != template {
+		t.Errorf("sent %q as %q, want the template as application/json", body, contentType)
+	}
+}
@@ -0,0 +1,89 @@
+package embed
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"confluence-exporter/internal/config"
+)
+
+// Embedder computes embedding vectors of texts
+type Embedder interface {
+	// Model identifies the vector space. Vectors of different models can't
+	// be compared.
+	Model() string
+	// Embed returns one vector per text, in the order of texts
+	Embed(texts []string) ([][]float32, error)
+}
+
+// Factory creates an embedder from the embedding configuration
+type Factory func(cfg config.EmbeddingConfig) (Embedder, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes an embedder available under the given provider name.
+// It panics if the name is empty or already registered.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" || factory == nil {
+		panic("embed: Register called with empty name or nil factory")
+	}
+	if _, exists := registry[name]; exists {
+		panic("embed: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// Names returns the sorted list of registered providers
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the embedder registered for cfg.Provider. It returns nil if no
+// provider is configured.
+func New(cfg config.EmbeddingConfig) (Embedder, error) {
+	if cfg.Provider == "" {
+		return nil, nil
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[cfg.Provider]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding provider %q (available: %s)", cfg.Provider, strings.Join(Names(), ", "))
+	}
+	return factory(cfg)
+}
+
+// Batches embeds texts in batches of at most size texts
+func Batches(e Embedder, texts []string, size int) ([][]float32, error) {
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += size {
+		end := min(start+size, len(texts))
+		batch, err := e.Embed(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("embedder %s returned %d vectors for %d texts", e.Model(), len(batch), end-start)
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
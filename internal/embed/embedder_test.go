@@ -0,0 +1,121 @@
+package embed
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"confluence-exporter/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.EmbeddingConfig
+		wantModel string
+		wantErr   string
+	}{
+		{name: "no provider", cfg: config.EmbeddingConfig{}},
+		{name: "hash", cfg: config.EmbeddingConfig{Provider: "hash"}, wantModel: "hash-256"},
+		{name: "hash with dimensions", cfg: config.EmbeddingConfig{Provider: "hash", Dimensions: 64}, wantModel: "hash-64"},
+		{name: "openai", cfg: config.EmbeddingConfig{Provider: "openai", Model: "m"}, wantModel: "m"},
+		{name: "openai without model", cfg: config.EmbeddingConfig{Provider: "openai"}, wantErr: "needs a model"},
+		{name: "unknown", cfg: config.EmbeddingConfig{Provider: "word2vec"}, wantErr: `unknown embedding provider "word2vec" (available: hash, openai)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedder, err := New(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("New error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if tt.wantModel == "" {
+				if embedder != nil {
+					t.Fatalf("New = %v, want no embedder", embedder)
+				}
+				return
+			}
+			if got := embedder.Model(); got != tt.wantModel {
+				t.Errorf("Model = %q, want %q", got, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestOpenAIEmbedderDefaultURL(t *testing.T) {
+	embedder, err := New(config.EmbeddingConfig{Provider: "openai", Model: "m"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := embedder.(*OpenAIEmbedder).BaseURL; got != defaultOpenAIURL {
+		t.Errorf("BaseURL = %q, want %q", got, defaultOpenAIURL)
+	}
+}
+
+// shortEmbedder returns one vector less than asked for
+type shortEmbedder struct{}
+
+func (shortEmbedder) Model() string { return "short" }
+
+func (shortEmbedder) Embed(texts []string) ([][]float32, error) {
+	return make([][]float32, len(texts)-1), nil
+}
+
+func TestBatchesChecksVectorCount(t *testing.T) {
+	_, err := Batches(shortEmbedder{}, []string{"a", "b"}, 2)
+	if err == nil || err.Error() != "embedder short returned 1 vectors for 2 texts" {
+		t.Errorf("Batches error = %v", err)
+	}
+}
+
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+func TestHashEmbedder(t *testing.T) {
+	embedder, err := New(config.EmbeddingConfig{Provider: "hash", Dimensions: 64})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	texts := []string{
+		"Roll back the deployment",
+		"How to roll back a deployment?",
+		"Quarterly budget planning",
+		"",
+	}
+	vectors, err := embedder.Embed(texts)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+
+	for i, vector := range vectors[:3] {
+		if len(vector) != 64 {
+			t.Fatalf("vector %d has %d dimensions, want 64", i, len(vector))
+		}
+		if norm := math.Sqrt(cosine(vector, vector)); math.Abs(norm-1) > 1e-5 {
+			t.Errorf("vector %d has length %f, want 1", i, norm)
+		}
+	}
+	if fmt.Sprint(vectors[3]) != fmt.Sprint(make([]float32, 64)) {
+		t.Errorf("empty text embedded as %v, want the zero vector", vectors[3])
+	}
+
+	again, _ := embedder.Embed(texts[:1])
+	if fmt.Sprint(again[0]) != fmt.Sprint(vectors[0]) {
+		t.Errorf("embedding the same text twice gave different vectors")
+	}
+	if related, unrelated := cosine(vectors[0], vectors[1]), cosine(vectors[0], vectors[2]); related <= unrelated {
+		t.Errorf("similarity of texts sharing words = %f, not above unrelated texts = %f", related, unrelated)
+	}
+}
@@ -0,0 +1,80 @@
+package embed
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"confluence-exporter/internal/config"
+)
+
+// defaultHashDimensions is the vector size of the hash embedder if none is configured
+const defaultHashDimensions = 256
+
+func init() {
+	Register("hash", newHashEmbedder)
+}
+
+// HashEmbedder embeds texts without a model by hashing their words into a
+// fixed number of dimensions. The vectors are deterministic and only capture
+// shared words, not meaning, which is enough for offline runs and tests.
+type HashEmbedder struct {
+	dimensions int
+}
+
+func newHashEmbedder(cfg config.EmbeddingConfig) (Embedder, error) {
+	dimensions := cfg.Dimensions
+	if dimensions <= 0 {
+		dimensions = defaultHashDimensions
+	}
+	return &HashEmbedder{dimensions: dimensions}, nil
+}
+
+// Model identifies the hash embedder and its number of dimensions
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d", e.dimensions)
+}
+
+// Embed returns the normalized vectors of the texts
+func (e *HashEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.vector(text)
+	}
+	return vectors, nil
+}
+
+// vector adds +1 or -1 for every word of text to the dimension picked by the
+// word's hash, and normalizes the result to unit length
+func (e *HashEmbedder) vector(text string) []float32 {
+	vector := make([]float32, e.dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+
+		// The top bit picks the sign, so collisions tend to cancel out
+		sign := float32(1)
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		vector[sum%uint64(e.dimensions)] += sign
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
@@ -0,0 +1,128 @@
+package embed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"confluence-exporter/internal/config"
+)
+
+// defaultOpenAIURL is the API the openai embedder talks to if no URL is configured
+const defaultOpenAIURL = "https://api.openai.com/v1"
+
+func init() {
+	Register("openai", newOpenAIEmbedder)
+}
+
+// OpenAIEmbedder gets embeddings from the /embeddings endpoint of the OpenAI
+// API or a compatible server, like Ollama, LocalAI or vLLM
+type OpenAIEmbedder struct {
+	BaseURL    string
+	APIKey     string
+	ModelName  string
+	Dimensions int
+	HTTPClient *http.Client
+}
+
+func newOpenAIEmbedder(cfg config.EmbeddingConfig) (Embedder, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("the openai embedding provider needs a model")
+	}
+	url := cfg.URL
+	if url == "" {
+		url = defaultOpenAIURL
+	}
+	return &OpenAIEmbedder{
+		BaseURL:    strings.TrimSuffix(url, "/"),
+		APIKey:     cfg.APIKey,
+		ModelName:  cfg.Model,
+		Dimensions: cfg.Dimensions,
+		HTTPClient: &http.Client{
+			Timeout: 120 * time.Second,
+		},
+	}, nil
+}
+
+// Model returns the name of the embedding model, with the requested number
+// of dimensions if one is configured
+func (e *OpenAIEmbedder) Model() string {
+	if e.Dimensions > 0 {
+		return fmt.Sprintf("%s-%d", e.ModelName, e.Dimensions)
+	}
+	return e.ModelName
+}
+
+// Embed sends the texts in one request and returns their vectors
+func (e *OpenAIEmbedder) Embed(texts []string) ([][]float32, error) {
+	request := struct {
+		Model      string   `json:"model"`
+		Input      []string `json:"input"`
+		Dimensions int      `json:"dimensions,omitempty"`
+	}{e.ModelName, texts, e.Dimensions}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", e.BaseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var answer struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &answer) == nil && answer.Error.Message != "" {
+			message = answer.Error.Message
+		}
+		return nil, fmt.Errorf("embedding request failed: %d %s: %s", resp.StatusCode, http.StatusText(resp.StatusCode), message)
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %v", err)
+	}
+
+	// The vectors are matched to the texts by index, not by their order
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding for unknown input %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("no embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
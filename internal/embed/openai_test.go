@@ -0,0 +1,157 @@
+package embed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"confluence-exporter/internal/config"
+)
+
+// embeddingsRequest is the body sent to /embeddings
+type embeddingsRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions"`
+}
+
+// newOpenAIServer starts an OpenAI-compatible stand-in. It embeds every input
+// as [batch, position, length] and answers in reverse order, like servers
+// that don't keep the input order.
+func newOpenAIServer(t *testing.T, requests *[]embeddingsRequest) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/embeddings" {
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"Incorrect API key provided"}}`)
+			return
+		}
+
+		var req embeddingsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, req)
+
+		type item struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var data []item
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, item{Index: i, Embedding: []float32{float32(len(*requests)), float32(i), float32(len(req.Input[i]))}})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestOpenAIEmbedder(t *testing.T, url string, dimensions int) Embedder {
+	embedder, err := New(config.EmbeddingConfig{Provider: "openai", URL: url + "/v1/", APIKey: "key", Model: "text-embedding-3-small", Dimensions: dimensions})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return embedder
+}
+
+func TestOpenAIEmbedderMatchesVectorsByIndex(t *testing.T) {
+	var requests []embeddingsRequest
+	server := newOpenAIServer(t, &requests)
+	embedder := newTestOpenAIEmbedder(t, server.URL, 256)
+
+	vectors, err := embedder.Embed([]string{"a", "bb", "ccc"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+
+	if got := fmt.Sprint(vectors); got != "[[1 0 1] [1 1 2] [1 2 3]]" {
+		t.Errorf("vectors = %s, want them in input order", got)
+	}
+	want := embeddingsRequest{Model: "text-embedding-3-small", Input: []string{"a", "bb", "ccc"}, Dimensions: 256}
+	if len(requests) != 1 || fmt.Sprint(requests[0]) != fmt.Sprint(want) {
+		t.Errorf("requests = %+v, want %+v", requests, want)
+	}
+	if got := embedder.Model(); got != "text-embedding-3-small-256" {
+		t.Errorf("Model = %q", got)
+	}
+}
+
+func TestBatchesSplitsRequests(t *testing.T) {
+	var requests []embeddingsRequest
+	server := newOpenAIServer(t, &requests)
+	embedder := newTestOpenAIEmbedder(t, server.URL, 0)
+
+	vectors, err := Batches(embedder, []string{"a", "bb", "ccc", "dddd", "eeeee"}, 2)
+	if err != nil {
+		t.Fatalf("Batches: %v", err)
+	}
+
+	var inputs []string
+	for _, req := range requests {
+		inputs = append(inputs, strings.Join(req.Input, ","))
+		if req.Dimensions != 0 {
+			t.Errorf("dimensions = %d sent without being configured", req.Dimensions)
+		}
+	}
+	if got := strings.Join(inputs, " | "); got != "a,bb | ccc,dddd | eeeee" {
+		t.Errorf("batches = %s", got)
+	}
+	if got := fmt.Sprint(vectors); got != "[[1 0 1] [1 1 2] [2 0 3] [2 1 4] [3 0 5]]" {
+		t.Errorf("vectors = %s", got)
+	}
+	if got := embedder.Model(); got != "text-embedding-3-small" {
+		t.Errorf("Model = %q", got)
+	}
+}
+
+func TestOpenAIEmbedderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "API error", status: http.StatusTooManyRequests, body: `{"error":{"message":"Rate limit reached"}}`, wantErr: "429 Too Many Requests: Rate limit reached"},
+		{name: "plain error", status: http.StatusBadGateway, body: "upstream down\n", wantErr: "502 Bad Gateway: upstream down"},
+		{name: "invalid JSON", status: http.StatusOK, body: `{"data":`, wantErr: "failed to decode embeddings"},
+		{name: "missing vector", status: http.StatusOK, body: `{"data":[{"index":0,"embedding":[1]}]}`, wantErr: "no embedding for input 1"},
+		{name: "unknown index", status: http.StatusOK, body: `{"data":[{"index":0,"embedding":[1]},{"index":2,"embedding":[1]}]}`, wantErr: "embedding for unknown input 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+			embedder := newTestOpenAIEmbedder(t, server.URL, 0)
+
+			_, err := embedder.Embed([]string{"a", "b"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Embed error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAIEmbedderRejectedKey(t *testing.T) {
+	var requests []embeddingsRequest
+	server := newOpenAIServer(t, &requests)
+	embedder, err := New(config.EmbeddingConfig{Provider: "openai", URL: server.URL + "/v1", APIKey: "wrong", Model: "m"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	_, err = embedder.Embed([]string{"a"})
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized: Incorrect API key provided") {
+		t.Errorf("Embed error = %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package meilisearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Task states reported by MeiliSearch
+const (
+	TaskEnqueued   = "enqueued"
+	TaskProcessing = "processing"
+	TaskSucceeded  = "succeeded"
+	TaskFailed     = "failed"
+	TaskCanceled   = "canceled"
+)
+
+// Client talks to the HTTP API of a MeiliSearch instance
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+	// PollInterval is the initial delay between two task status requests
+	PollInterval time.Duration
+	// TaskTimeout is how long WaitTask waits for a task to finish
+	TaskTimeout time.Duration
+}
+
+// NewClient creates a client for the MeiliSearch instance at baseURL. The API
+// key may be empty if the instance has no master key.
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		APIKey:  apiKey,
+		HTTPClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		PollInterval: 100 * time.Millisecond,
+		TaskTimeout:  10 * time.Minute,
+	}
+}
+
+// Settings are the index settings managed by the exporter
+type Settings struct {
+	SearchableAttributes []string `json:"searchableAttributes,omitempty"`
+	FilterableAttributes []string `json:"filterableAttributes,omitempty"`
+	SortableAttributes   []string `json:"sortableAttributes,omitempty"`
+}
+
+// Task is an asynchronous operation of MeiliSearch
+type Task struct {
+	UID    int64      `json:"uid"`
+	Status string     `json:"status"`
+	Type   string     `json:"type"`
+	Error  *TaskError `json:"error"`
+}
+
+// TaskError describes why a task failed
+type TaskError struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+}
+
+// Error is returned when MeiliSearch answers with a non-2xx status code
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+	Code       string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// enqueuedTask is the answer to requests that start a task
+type enqueuedTask struct {
+	TaskUID int64 `json:"taskUid"`
+}
+
+// EnsureIndex creates the index with the given primary key if it doesn't
+// exist yet and waits until it is created
+func (c *Client) EnsureIndex(index, primaryKey string) error {
+	err := c.do("GET", "/indexes/"+url.PathEscape(index), nil, nil)
+	if err == nil {
+		return nil
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "index_not_found" {
+		return err
+	}
+
+	body := map[string]string{"uid": index, "primaryKey": primaryKey}
+	var task enqueuedTask
+	if err := c.do("POST", "/indexes", body, &task); err != nil {
+		return err
+	}
+	return c.WaitTask(task.TaskUID)
+}
+
+// UpdateSettings changes the settings of the index and returns the task ID
+func (c *Client) UpdateSettings(index string, settings Settings) (int64, error) {
+	var task enqueuedTask
+	err := c.do("PATCH", "/indexes/"+url.PathEscape(index)+"/settings", settings, &task)
+	return task.TaskUID, err
+}
+
+// AddDocuments adds or replaces documents in the index and returns the task ID
+func (c *Client) AddDocuments(index, primaryKey string, documents any) (int64, error) {
+	path := "/indexes/" + url.PathEscape(index) + "/documents?primaryKey=" + url.QueryEscape(primaryKey)
+	var task enqueuedTask
+	err := c.do("POST", path, documents, &task)
+	return task.TaskUID, err
+}
+
+// DeleteDocuments removes the documents with the given IDs from the index and
+// returns the task ID
+func (c *Client) DeleteDocuments(index string, ids []string) (int64, error) {
+	var task enqueuedTask
+	err := c.do("POST", "/indexes/"+url.PathEscape(index)+"/documents/delete-batch", ids, &task)
+	return task.TaskUID, err
+}
+
+// GetTask returns the current state of a task
+func (c *Client) GetTask(id int64) (Task, error) {
+	var task Task
+	err := c.do("GET", fmt.Sprintf("/tasks/%d", id), nil, &task)
+	return task, err
+}
+
+// WaitTask polls the task until it is finished. It returns an error if the
+// task failed, was canceled or didn't finish within TaskTimeout.
+func (c *Client) WaitTask(id int64) error {
+	deadline := time.Now().Add(c.TaskTimeout)
+	interval := c.PollInterval
+
+	for {
+		task, err := c.GetTask(id)
+		if err != nil {
+			return err
+		}
+
+		switch task.Status {
+		case TaskSucceeded:
+			return nil
+		case TaskFailed:
+			if task.Error != nil {
+				return fmt.Errorf("MeiliSearch task %d (%s) failed: %s", id, task.Type, task.Error.Message)
+			}
+			return fmt.Errorf("MeiliSearch task %d (%s) failed", id, task.Type)
+		case TaskCanceled:
+			return fmt.Errorf("MeiliSearch task %d (%s) was canceled", id, task.Type)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("MeiliSearch task %d (%s) still %s after %v", id, task.Type, task.Status, c.TaskTimeout)
+		}
+		time.Sleep(interval)
+		interval = min(interval*2, 2*time.Second)
+	}
+}
+
+// do sends a request with an optional JSON body and decodes the JSON answer
+// into result if it is not nil
+func (c *Client) do(method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &Error{Method: method, Path: path, StatusCode: resp.StatusCode}
+		var answer struct {
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		}
+		if data, err := io.ReadAll(resp.Body); err == nil && json.Unmarshal(data, &answer) == nil {
+			e.Message, e.Code = answer.Message, answer.Code
+		}
+		return e
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode answer to %s %s: %v", method, path, err)
+	}
+	return nil
+}
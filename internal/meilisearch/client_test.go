@@ -0,0 +1,130 @@
+package meilisearch_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"confluence-exporter/internal/meilisearch"
+	"confluence-exporter/internal/meilisearch/meilisearchtest"
+)
+
+// newTestServer starts a MeiliSearch stand-in whose tasks are processing for
+// two polls, and a client for it
+func newTestServer(t *testing.T) (*meilisearchtest.Server, *meilisearch.Client) {
+	server := meilisearchtest.NewServer(t, "secret")
+	server.ProcessingPolls = 2
+
+	client := meilisearch.NewClient(server.URL+"/", "secret")
+	client.PollInterval = time.Millisecond
+	return server, client
+}
+
+func TestEnsureIndexCreatesMissingIndex(t *testing.T) {
+	server, client := newTestServer(t)
+
+	if err := client.EnsureIndex("confluence", "uid"); err != nil {
+		t.Fatalf("EnsureIndex: %v", err)
+	}
+
+	want := []string{"GET /indexes/confluence", "POST /indexes", "GET /tasks/1", "GET /tasks/1", "GET /tasks/1"}
+	if got := strings.Join(server.Requests(), ", "); got != strings.Join(want, ", ") {
+		t.Errorf("requests = %s, want %s", got, strings.Join(want, ", "))
+	}
+	if got := server.Bodies("POST /indexes")[0]; got != `{"primaryKey":"uid","uid":"confluence"}` {
+		t.Errorf("create index body = %s", got)
+	}
+}
+
+func TestEnsureIndexKeepsExistingIndex(t *testing.T) {
+	server, client := newTestServer(t)
+	server.AddIndex("confluence")
+
+	if err := client.EnsureIndex("confluence", "uid"); err != nil {
+		t.Fatalf("EnsureIndex: %v", err)
+	}
+	if requests := server.Requests(); len(requests) != 1 {
+		t.Errorf("requests = %v, want only the index lookup", requests)
+	}
+}
+
+func TestErrorsCarryStatusAndCode(t *testing.T) {
+	_, client := newTestServer(t)
+	client.APIKey = "wrong"
+
+	err := client.EnsureIndex("confluence", "uid")
+	var apiErr *meilisearch.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("EnsureIndex error = %v, want *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != "invalid_api_key" {
+		t.Errorf("error = %d %s, want 401 invalid_api_key", apiErr.StatusCode, apiErr.Code)
+	}
+	if !strings.Contains(err.Error(), "The provided API key is invalid.") {
+		t.Errorf("error message %q lacks the message of MeiliSearch", err)
+	}
+}
+
+func TestAddDocuments(t *testing.T) {
+	server, client := newTestServer(t)
+
+	docs := []map[string]string{{"uid": "1", "title": "Home"}}
+	task, err := client.AddDocuments("my index", "uid", docs)
+	if err != nil {
+		t.Fatalf("AddDocuments: %v", err)
+	}
+	if task != 1 {
+		t.Errorf("task = %d, want 1", task)
+	}
+	if got := server.Requests()[0]; got != "POST /indexes/my index/documents" {
+		t.Errorf("request = %s", got)
+	}
+	if got := server.Bodies("POST /indexes/my index/documents")[0]; got != `[{"title":"Home","uid":"1"}]` {
+		t.Errorf("body = %s", got)
+	}
+}
+
+func TestWaitTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		timeout time.Duration
+		wantErr string
+	}{
+		{name: "succeeded", status: meilisearch.TaskSucceeded},
+		{name: "failed", status: meilisearch.TaskFailed, wantErr: "task 1 (documentAdditionOrUpdate) failed: bad id"},
+		{name: "canceled", status: meilisearch.TaskCanceled, wantErr: "task 1 (documentAdditionOrUpdate) was canceled"},
+		{name: "timeout", status: meilisearch.TaskProcessing, timeout: 5 * time.Millisecond, wantErr: "still processing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestServer(t)
+			server.SetTaskStatus(1, tt.status)
+			server.TaskError = "bad id"
+			if tt.timeout > 0 {
+				client.TaskTimeout = tt.timeout
+			}
+
+			task, err := client.DeleteDocuments("confluence", []string{"1"})
+			if err != nil {
+				t.Fatalf("DeleteDocuments: %v", err)
+			}
+			err = client.WaitTask(task)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("WaitTask: %v", err)
+				}
+				if polls := server.Polls(); polls != 3 {
+					t.Errorf("polls = %d, want 3", polls)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("WaitTask error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
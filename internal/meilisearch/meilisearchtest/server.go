@@ -0,0 +1,154 @@
+// Package meilisearchtest provides a MeiliSearch stand-in for tests of the
+// client and of the outputs that index pages.
+package meilisearchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"confluence-exporter/internal/meilisearch"
+)
+
+// Server records the requests it receives and answers like MeiliSearch:
+// every write enqueues a task, which reports processing for ProcessingPolls
+// polls before it finishes.
+type Server struct {
+	// URL is the base URL of the server
+	URL string
+	// APIKey is the key clients must send, any request is accepted if empty
+	APIKey string
+	// ProcessingPolls is the number of polls a new task stays in processing
+	ProcessingPolls int
+	// TaskError is the error message of failed tasks
+	TaskError string
+
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string][]string
+	indexes  map[string]bool
+	status   map[int64]string
+	pending  map[int64]int
+	polls    int
+	nextUID  int64
+}
+
+// NewServer starts a server that requires apiKey and is closed when the test
+// ends
+func NewServer(t testing.TB, apiKey string) *Server {
+	s := &Server{
+		APIKey:  apiKey,
+		bodies:  make(map[string][]string),
+		indexes: make(map[string]bool),
+		status:  make(map[int64]string),
+		pending: make(map[int64]int),
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	s.URL = server.URL
+	return s
+}
+
+// AddIndex creates an index as if it existed before
+func (s *Server) AddIndex(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexes[uid] = true
+}
+
+// SetTaskStatus sets the status the task reports once it finished
+func (s *Server) SetTaskStatus(uid int64, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status[uid] = status
+}
+
+// Requests returns the method and path of the requests received so far
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Bodies returns the bodies of the requests to route, like "POST /indexes"
+func (s *Server) Bodies(route string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies[route]...)
+}
+
+// Polls returns the number of task lookups
+func (s *Server) Polls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls
+}
+
+// ServeHTTP answers a MeiliSearch API request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+s.APIKey {
+		answer(w, http.StatusUnauthorized, map[string]string{"message": "The provided API key is invalid.", "code": "invalid_api_key"})
+		return
+	}
+
+	route := r.Method + " " + r.URL.Path
+	s.requests = append(s.requests, route)
+	body, _ := io.ReadAll(r.Body)
+	s.bodies[route] = append(s.bodies[route], string(body))
+
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/indexes/"):
+		uid := strings.TrimPrefix(r.URL.Path, "/indexes/")
+		if !s.indexes[uid] {
+			answer(w, http.StatusNotFound, map[string]string{"message": "Index not found.", "code": "index_not_found"})
+			return
+		}
+		answer(w, http.StatusOK, map[string]string{"uid": uid})
+	case r.Method == "POST" && r.URL.Path == "/indexes":
+		var index struct {
+			UID string `json:"uid"`
+		}
+		json.Unmarshal(body, &index)
+		s.indexes[index.UID] = true
+		s.enqueue(w)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/tasks/"):
+		var uid int64
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/tasks/"), "%d", &uid)
+		s.polls++
+		task := meilisearch.Task{UID: uid, Type: "documentAdditionOrUpdate", Status: meilisearch.TaskProcessing}
+		if s.pending[uid] > 0 {
+			s.pending[uid]--
+		} else if status, ok := s.status[uid]; ok {
+			task.Status = status
+		} else {
+			task.Status = meilisearch.TaskSucceeded
+		}
+		if task.Status == meilisearch.TaskFailed {
+			task.Error = &meilisearch.TaskError{Message: s.TaskError, Code: "invalid_document_id"}
+		}
+		answer(w, http.StatusOK, task)
+	default:
+		s.enqueue(w)
+	}
+}
+
+// enqueue answers with a new task
+func (s *Server) enqueue(w http.ResponseWriter) {
+	s.nextUID++
+	s.pending[s.nextUID] = s.ProcessingPolls
+	answer(w, http.StatusAccepted, map[string]any{"taskUid": s.nextUID, "status": meilisearch.TaskEnqueued})
+}
+
+func answer(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -2,19 +2,29 @@ package models
 
 // Page represents a Confluence page with all its metadata and content
 type Page struct {
-	ID          string       `json:"id"`
-	Title       string       `json:"title"`
-	SpaceKey    string       `json:"spaceKey"`
-	Version     int          `json:"version"`
-	Content     string       `json:"content"`
-	ParentID    string       `json:"parentId,omitempty"`
-	URL         string       `json:"url"`
-	CreatedAt   string       `json:"createdAt"`
-	UpdatedAt   string       `json:"updatedAt"`
-	CreatedBy   string       `json:"createdBy"`
-	UpdatedBy   string       `json:"updatedBy"`
-	Labels      []Label      `json:"labels,omitempty"`
-	Attachments []Attachment `json:"attachments,omitempty"`
+	ID             string       `json:"id"`
+	Title          string       `json:"title"`
+	SpaceKey       string       `json:"spaceKey"`
+	SpaceName      string       `json:"spaceName,omitempty"`
+	Version        int          `json:"version"`
+	Content        string       `json:"content"`
+	ParentID       string       `json:"parentId,omitempty"`
+	Ancestors      []Ancestor   `json:"ancestors,omitempty"`
+	URL            string       `json:"url"`
+	CreatedAt      string       `json:"createdAt"`
+	UpdatedAt      string       `json:"updatedAt"`
+	CreatedBy      string       `json:"createdBy"`
+	UpdatedBy      string       `json:"updatedBy"`
+	VersionMessage string       `json:"versionMessage,omitempty"`
+	Labels         []Label      `json:"labels,omitempty"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+	Links          []Link       `json:"links,omitempty"`
+}
+
+// Ancestor is a page above a page in the hierarchy, listed from the root down
+type Ancestor struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
 }
 
 // Label represents a Confluence content label
@@ -33,6 +43,15 @@ type Attachment struct {
 	DownloadURL string `json:"downloadUrl"`
 }
 
+// Link is a link from a page to another page or an external URL
+type Link struct {
+	// PageID is the ID of the target page, if it is known
+	PageID   string `json:"pageId,omitempty"`
+	SpaceKey string `json:"spaceKey,omitempty"`
+	Title    string `json:"title,omitempty"`
+	URL      string `json:"url"`
+}
+
 // Space represents a Confluence space
 type Space struct {
 	Key         string `json:"key"`
@@ -40,3 +59,13 @@ type Space struct {
 	Description string `json:"description,omitempty"`
 	Type        string `json:"type"`
 }
+
+// PageVersion is a historical version of a Confluence page
+type PageVersion struct {
+	Number    int    `json:"number"`
+	Title     string `json:"title,omitempty"`
+	Content   string `json:"content,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	CreatedBy string `json:"createdBy"`
+	Message   string `json:"message,omitempty"`
+}
@@ -0,0 +1,80 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// appendFile is a buffered, append-only output file whose position can be
+// checkpointed and restored when an interrupted export is resumed
+type appendFile struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	offset int64
+}
+
+// createAppendFile creates (or truncates) the file at path
+func createAppendFile(path string) (*appendFile, error) {
+	return openAppendFile(path, "")
+}
+
+// openAppendFile opens the file at path and discards everything written
+// after marker. An empty marker truncates the file.
+func openAppendFile(path, marker string) (*appendFile, error) {
+	var offset int64
+	if marker != "" {
+		var err error
+		if offset, err = strconv.ParseInt(marker, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint marker %q for %s", marker, path)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &appendFile{path: path, file: file, writer: bufio.NewWriter(file), offset: offset}, nil
+}
+
+func (f *appendFile) Write(p []byte) (int, error) {
+	n, err := f.writer.Write(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+// Checkpoint flushes the file to disk and returns the current position
+func (f *appendFile) Checkpoint() (string, error) {
+	if err := f.writer.Flush(); err != nil {
+		return "", err
+	}
+	if err := f.file.Sync(); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(f.offset, 10), nil
+}
+
+// Close flushes and closes the file
+func (f *appendFile) Close() error {
+	if err := f.writer.Flush(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
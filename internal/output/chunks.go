@@ -0,0 +1,304 @@
+package output
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"confluence-exporter/internal/chunker"
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/db"
+	"confluence-exporter/internal/embed"
+	"confluence-exporter/internal/models"
+)
+
+// ChunksFile is the JSONL file written by the chunks handler
+const ChunksFile = "confluence_chunks.jsonl"
+
+// ChunksDeletesFile lists the IDs of deleted pages whose chunks must be
+// removed from the retrieval index
+const ChunksDeletesFile = "confluence_chunks_deletes.json"
+
+func init() {
+	Register("chunks", newChunksHandler)
+}
+
+// ChunkRecord is a chunk of a page with the metadata needed to cite it
+type ChunkRecord struct {
+	ID         string   `json:"id"`
+	PageID     string   `json:"page_id"`
+	ChunkIndex int      `json:"chunk_index"`
+	SpaceKey   string   `json:"space_key"`
+	Title      string   `json:"title"`
+	Breadcrumb []string `json:"breadcrumb"`
+	Headings   []string `json:"headings"`
+	URL        string   `json:"url"`
+	Text       string   `json:"text"`
+	Tokens     int      `json:"tokens"`
+	Characters int      `json:"characters"`
+	// Embedding is the vector of Text, with embeddings enabled
+	Embedding      []float32 `json:"embedding,omitempty"`
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+}
+
+// newChunkRecords splits the page's Markdown into chunks
+func newChunkRecords(page models.Page, markdown string, opts chunker.Options) []ChunkRecord {
+	// The breadcrumb is the path to the page as shown by Confluence
+	space := page.SpaceName
+	if space == "" {
+		space = page.SpaceKey
+	}
+	breadcrumb := []string{space}
+	for _, ancestor := range page.Ancestors {
+		breadcrumb = append(breadcrumb, ancestor.Title)
+	}
+	breadcrumb = append(breadcrumb, page.Title)
+
+	var records []ChunkRecord
+	for i, chunk := range chunker.Split(markdown, opts) {
+		headings := chunk.Headings
+		if headings == nil {
+			headings = []string{}
+		}
+		records = append(records, ChunkRecord{
+			ID:         fmt.Sprintf("%s-%d", page.ID, i),
+			PageID:     page.ID,
+			ChunkIndex: i,
+			SpaceKey:   page.SpaceKey,
+			Title:      page.Title,
+			Breadcrumb: breadcrumb,
+			Headings:   headings,
+			URL:        page.URL,
+			Text:       chunk.Text,
+			Tokens:     chunker.EstimateTokens(chunk.Text),
+			Characters: utf8.RuneCountInString(chunk.Text),
+		})
+	}
+	return records
+}
+
+// chunkEmbedder adds embedding vectors to chunk records
+type chunkEmbedder struct {
+	embedder  embed.Embedder
+	batchSize int
+}
+
+// embed computes the embeddings of the records, if an embedder is configured
+func (e chunkEmbedder) embed(records []ChunkRecord) error {
+	if e.embedder == nil || len(records) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(records))
+	for i, record := range records {
+		texts[i] = record.Text
+	}
+	vectors, err := embed.Batches(e.embedder, texts, e.batchSize)
+	if err != nil {
+		return fmt.Errorf("failed to embed chunks of page %s: %v", records[0].PageID, err)
+	}
+	for i := range records {
+		records[i].Embedding = vectors[i]
+		records[i].EmbeddingModel = e.embedder.Model()
+	}
+	return nil
+}
+
+func newChunksHandler(cfg config.ExportConfig) (Handler, error) {
+	opts := chunker.Options{
+		Unit:    cfg.Chunks.Unit,
+		Size:    cfg.Chunks.Size,
+		Overlap: cfg.Chunks.Overlap,
+	}
+	embedder, err := embed.New(cfg.Chunks.Embeddings)
+	if err != nil {
+		return nil, err
+	}
+	embedding := chunkEmbedder{embedder: embedder, batchSize: cfg.Chunks.Embeddings.BatchSize}
+
+	if cfg.Chunks.Format == "duckdb" {
+		return &ChunksDBHandler{path: DBFile, opts: opts, embedding: embedding}, nil
+	}
+	return &ChunksHandler{
+		path:        filepath.Join(cfg.OutputDir, ChunksFile),
+		deletesPath: filepath.Join(cfg.OutputDir, ChunksDeletesFile),
+		opts:        opts,
+		embedding:   embedding,
+	}, nil
+}
+
+// ChunksHandler writes the chunks of all pages to a JSONL file, one chunk per line
+type ChunksHandler struct {
+	path        string
+	deletesPath string
+	opts        chunker.Options
+	embedding   chunkEmbedder
+	writer      *appendFile
+	deletes     []string
+}
+
+// Initialize creates the JSONL file
+func (h *ChunksHandler) Initialize() error {
+	writer, err := createAppendFile(h.path)
+	if err != nil {
+		return err
+	}
+	h.writer = writer
+	return nil
+}
+
+// Resume reopens the JSONL file of an interrupted run
+func (h *ChunksHandler) Resume(marker string) error {
+	writer, err := openAppendFile(h.path, marker)
+	if err != nil {
+		return err
+	}
+	h.writer = writer
+	return nil
+}
+
+// Checkpoint flushes the JSONL file to disk
+func (h *ChunksHandler) Checkpoint() (string, error) {
+	return h.writer.Checkpoint()
+}
+
+// SavePage appends the chunks of the page
+func (h *ChunksHandler) SavePage(page models.Page, markdown string) error {
+	records := newChunkRecords(page, markdown, h.opts)
+	if err := h.embedding.embed(records); err != nil {
+		return err
+	}
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := h.writer.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MovePage is a no-op, the JSONL file is rewritten on every run
+func (h *ChunksHandler) MovePage(from, to models.Page) error {
+	return nil
+}
+
+// RemovePage adds the page to the list of deleted pages
+func (h *ChunksHandler) RemovePage(page models.Page) error {
+	h.deletes = append(h.deletes, page.ID)
+	return nil
+}
+
+// Location describes where the JSONL file was written
+func (h *ChunksHandler) Location() string {
+	return "Chunks saved to " + h.path
+}
+
+// Close closes the JSONL file and writes the deleted pages
+func (h *ChunksHandler) Close() error {
+	if h.writer == nil {
+		return nil
+	}
+	if err := h.writer.Close(); err != nil {
+		return err
+	}
+	h.writer = nil
+
+	// Only keep a deletes file around when this run removed pages
+	if len(h.deletes) == 0 {
+		if err := os.Remove(h.deletesPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(h.deletes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode deleted pages: %v", err)
+	}
+	if err := os.WriteFile(h.deletesPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", h.deletesPath, err)
+	}
+	return nil
+}
+
+// ChunksDBHandler stores the chunks of all pages in the chunks table of the
+// DuckDB database
+type ChunksDBHandler struct {
+	path      string
+	opts      chunker.Options
+	embedding chunkEmbedder
+	conn      *sql.DB
+}
+
+// Initialize opens the database and creates the schema
+func (h *ChunksDBHandler) Initialize() error {
+	conn, err := db.InitDB(h.path)
+	if err != nil {
+		return err
+	}
+	h.conn = conn
+	return nil
+}
+
+// SavePage replaces the chunks of the page
+func (h *ChunksDBHandler) SavePage(page models.Page, markdown string) error {
+	records := newChunkRecords(page, markdown, h.opts)
+	if err := h.embedding.embed(records); err != nil {
+		return err
+	}
+
+	var chunks []db.Chunk
+	for _, record := range records {
+		chunks = append(chunks, db.Chunk{
+			PageUID:        record.PageID,
+			Index:          record.ChunkIndex,
+			SpaceKey:       record.SpaceKey,
+			Title:          record.Title,
+			Breadcrumb:     record.Breadcrumb,
+			Headings:       record.Headings,
+			Link:           record.URL,
+			Text:           record.Text,
+			Tokens:         record.Tokens,
+			Characters:     record.Characters,
+			Embedding:      record.Embedding,
+			EmbeddingModel: record.EmbeddingModel,
+		})
+	}
+	return db.ReplaceChunks(h.conn, page.ID, chunks)
+}
+
+// MovePage is a no-op, chunks are keyed by page ID and replaced by SavePage
+func (h *ChunksDBHandler) MovePage(from, to models.Page) error {
+	return nil
+}
+
+// RemovePage deletes the page and its chunks
+func (h *ChunksDBHandler) RemovePage(page models.Page) error {
+	return db.DeletePage(h.conn, page.ID)
+}
+
+// Location describes where the database was written
+func (h *ChunksDBHandler) Location() string {
+	return "Chunks saved to " + h.path
+}
+
+// Close rebuilds the vector index for the similar command and closes the
+// database connection. Without the index, similar scans all chunks.
+func (h *ChunksDBHandler) Close() error {
+	if h.embedding.embedder != nil {
+		if err := db.CreateVectorIndex(h.conn); err != nil {
+			log.Printf("⚠️  Vector index unavailable, similar will scan all chunks: %v", err)
+		}
+	}
+	db.CloseDB(h.conn)
+	h.conn = nil
+	return nil
+}
@@ -0,0 +1,124 @@
+package output
+
+import (
+	"database/sql"
+	"log"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/db"
+	"confluence-exporter/internal/models"
+)
+
+// DBFile is the DuckDB database file written by the db handler
+const DBFile = "confluence_pages.db"
+
+func init() {
+	Register("db", newDBHandler)
+}
+
+// DBHandler stores pages in a DuckDB database
+type DBHandler struct {
+	path string
+	conn *sql.DB
+}
+
+func newDBHandler(cfg config.ExportConfig) (Handler, error) {
+	return &DBHandler{path: DBFile}, nil
+}
+
+// Initialize opens the database and creates the schema
+func (h *DBHandler) Initialize() error {
+	conn, err := db.InitDB(h.path)
+	if err != nil {
+		return err
+	}
+	h.conn = conn
+	return nil
+}
+
+// SavePage inserts or updates the page row with its space, labels,
+// attachments and links
+func (h *DBHandler) SavePage(page models.Page, markdown string) error {
+	row := db.Page{
+		UID:       page.ID,
+		SpaceKey:  page.SpaceKey,
+		SpaceName: page.SpaceName,
+		ParentID:  page.ParentID,
+		Title:     page.Title,
+		Version:   page.Version,
+		Markdown:  markdown,
+		Storage:   page.Content,
+		Link:      page.URL,
+		CreatedBy: page.CreatedBy,
+		CreatedAt: page.CreatedAt,
+		UpdatedBy: page.UpdatedBy,
+		UpdatedAt: page.UpdatedAt,
+	}
+	for _, label := range page.Labels {
+		row.Labels = append(row.Labels, db.Label{ID: label.ID, Name: label.Name})
+	}
+	for _, attachment := range page.Attachments {
+		row.Attachments = append(row.Attachments, db.Attachment{
+			ID:          attachment.ID,
+			FileName:    attachment.FileName,
+			MediaType:   attachment.MediaType,
+			FileSize:    attachment.FileSize,
+			DownloadURL: attachment.DownloadURL,
+		})
+	}
+	for _, link := range page.Links {
+		row.Links = append(row.Links, db.PageLink{
+			TargetUID:      link.PageID,
+			TargetSpaceKey: link.SpaceKey,
+			TargetTitle:    link.Title,
+			URL:            link.URL,
+		})
+	}
+	return db.InsertPage(h.conn, row)
+}
+
+// SaveVersion inserts or updates the row of a historical version of the page
+// in the page_versions table
+func (h *DBHandler) SaveVersion(page models.Page, version models.PageVersion, markdown string) error {
+	title := version.Title
+	if title == "" {
+		title = page.Title
+	}
+	return db.InsertPageVersion(h.conn, db.PageVersion{
+		PageUID:   page.ID,
+		Version:   version.Number,
+		Title:     title,
+		Author:    version.CreatedBy,
+		CreatedAt: version.CreatedAt,
+		Message:   version.Message,
+		Storage:   version.Content,
+		Markdown:  markdown,
+	})
+}
+
+// MovePage is a no-op, rows are keyed by page ID and updated by SavePage
+func (h *DBHandler) MovePage(from, to models.Page) error {
+	return nil
+}
+
+// RemovePage deletes the page row, its versions and its edges
+func (h *DBHandler) RemovePage(page models.Page) error {
+	return db.DeletePage(h.conn, page.ID)
+}
+
+// Location describes where the database was written
+func (h *DBHandler) Location() string {
+	return "Data saved to " + h.path
+}
+
+// Close rebuilds the full-text index for the search command and closes the
+// database connection. The export is kept if the index can't be built, for
+// example because the fts extension can't be downloaded.
+func (h *DBHandler) Close() error {
+	if err := db.CreateSearchIndex(h.conn); err != nil {
+		log.Printf("⚠️  Search will be unavailable: %v", err)
+	}
+	db.CloseDB(h.conn)
+	h.conn = nil
+	return nil
+}
@@ -0,0 +1,315 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/elasticsearch"
+	"confluence-exporter/internal/models"
+)
+
+// ElasticsearchBulkFile is the NDJSON file of bulk actions written by the
+// elasticsearch handler
+const ElasticsearchBulkFile = "confluence_pages_bulk.ndjson"
+
+// ElasticsearchTemplateFile is the index template written next to the bulk file
+const ElasticsearchTemplateFile = "confluence_pages_index_template.json"
+
+// maxBulkBytes caps the size of a single bulk request, below the default
+// http.max_content_length of Elasticsearch and OpenSearch
+const maxBulkBytes = 10 << 20
+
+func init() {
+	Register("elasticsearch", newElasticsearchHandler)
+	Register("opensearch", newElasticsearchHandler)
+}
+
+// ElasticsearchDocument is a single page as indexed by Elasticsearch or OpenSearch
+type ElasticsearchDocument struct {
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	URL       string   `json:"url"`
+	SpaceKey  string   `json:"space_key"`
+	SpaceName string   `json:"space_name,omitempty"`
+	ParentID  string   `json:"parent_id,omitempty"`
+	Version   int      `json:"version"`
+	CreatedAt string   `json:"created_at,omitempty"`
+	CreatedBy string   `json:"created_by,omitempty"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+	UpdatedBy string   `json:"updated_by,omitempty"`
+	Labels    []string `json:"labels,omitempty"`
+}
+
+// bulkAction is the action line of a bulk request
+type bulkAction struct {
+	Index  *bulkTarget `json:"index,omitempty"`
+	Delete *bulkTarget `json:"delete,omitempty"`
+}
+
+// bulkTarget is the document an action applies to
+type bulkTarget struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+// ElasticsearchHandler writes the pages as actions for the _bulk API of
+// Elasticsearch and OpenSearch, together with an index template. If a cluster
+// URL is configured, the template and the actions are sent on Close.
+type ElasticsearchHandler struct {
+	path         string
+	templatePath string
+	index        string
+	batchSize    int
+	client       *elasticsearch.Client
+	writer       *appendFile
+	sent         int
+}
+
+func newElasticsearchHandler(cfg config.ExportConfig) (Handler, error) {
+	h := &ElasticsearchHandler{
+		path:         filepath.Join(cfg.OutputDir, ElasticsearchBulkFile),
+		templatePath: filepath.Join(cfg.OutputDir, ElasticsearchTemplateFile),
+		index:        cfg.Elasticsearch.Index,
+		batchSize:    cfg.Elasticsearch.BatchSize,
+	}
+	if es := cfg.Elasticsearch; es.URL != "" {
+		h.client = elasticsearch.NewClient(es.URL, es.Username, es.Password, es.APIKey)
+	}
+	return h, nil
+}
+
+// newElasticsearchDocument builds the Elasticsearch document for a page
+func newElasticsearchDocument(page models.Page, markdown string) ElasticsearchDocument {
+	doc := ElasticsearchDocument{
+		Title:     page.Title,
+		Body:      markdown,
+		URL:       page.URL,
+		SpaceKey:  page.SpaceKey,
+		SpaceName: page.SpaceName,
+		ParentID:  page.ParentID,
+		Version:   page.Version,
+		CreatedAt: page.CreatedAt,
+		CreatedBy: page.CreatedBy,
+		UpdatedAt: page.UpdatedAt,
+		UpdatedBy: page.UpdatedBy,
+	}
+	for _, label := range page.Labels {
+		doc.Labels = append(doc.Labels, label.Name)
+	}
+	return doc
+}
+
+// elasticsearchTemplate is the composable index template for the index. Title
+// and body are analyzed with case and accent folding, the body with English
+// stemming as well; space key and labels are keywords for filters and
+// aggregations.
+func elasticsearchTemplate(index string) map[string]any {
+	keyword := map[string]any{"type": "keyword"}
+	date := map[string]any{"type": "date"}
+	return map[string]any{
+		"index_patterns": []string{index},
+		"template": map[string]any{
+			"settings": map[string]any{
+				"analysis": map[string]any{
+					"filter": map[string]any{
+						"confluence_english_stemmer": map[string]any{"type": "stemmer", "language": "english"},
+					},
+					"analyzer": map[string]any{
+						"confluence_title": map[string]any{
+							"type":      "custom",
+							"tokenizer": "standard",
+							"filter":    []string{"lowercase", "asciifolding"},
+						},
+						"confluence_body": map[string]any{
+							"type":      "custom",
+							"tokenizer": "standard",
+							"filter":    []string{"lowercase", "asciifolding", "confluence_english_stemmer"},
+						},
+					},
+				},
+			},
+			"mappings": map[string]any{
+				"properties": map[string]any{
+					"title": map[string]any{
+						"type":     "text",
+						"analyzer": "confluence_title",
+						"fields": map[string]any{
+							"keyword": map[string]any{"type": "keyword", "ignore_above": 256},
+							"stemmed": map[string]any{"type": "text", "analyzer": "confluence_body"},
+						},
+					},
+					"body":       map[string]any{"type": "text", "analyzer": "confluence_body"},
+					"url":        map[string]any{"type": "keyword", "index": false},
+					"space_key":  keyword,
+					"space_name": map[string]any{"type": "text", "fields": map[string]any{"keyword": keyword}},
+					"parent_id":  keyword,
+					"version":    map[string]any{"type": "integer"},
+					"created_at": date,
+					"created_by": keyword,
+					"updated_at": date,
+					"updated_by": keyword,
+					"labels":     keyword,
+				},
+			},
+		},
+	}
+}
+
+// Initialize writes the index template and creates the bulk file
+func (h *ElasticsearchHandler) Initialize() error {
+	return h.open("")
+}
+
+// Resume reopens the bulk file of an interrupted run
+func (h *ElasticsearchHandler) Resume(marker string) error {
+	return h.open(marker)
+}
+
+// open writes the index template and opens the bulk file at marker
+func (h *ElasticsearchHandler) open(marker string) error {
+	writer, err := openAppendFile(h.path, marker)
+	if err != nil {
+		return err
+	}
+	h.writer = writer
+
+	data, err := json.MarshalIndent(elasticsearchTemplate(h.index), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode index template: %v", err)
+	}
+	if err := os.WriteFile(h.templatePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", h.templatePath, err)
+	}
+	return nil
+}
+
+// Checkpoint flushes the bulk file to disk
+func (h *ElasticsearchHandler) Checkpoint() (string, error) {
+	return h.writer.Checkpoint()
+}
+
+// SavePage appends an index action with the page's document
+func (h *ElasticsearchHandler) SavePage(page models.Page, markdown string) error {
+	action := bulkAction{Index: &bulkTarget{Index: h.index, ID: page.ID}}
+	return h.writeLines(action, newElasticsearchDocument(page, markdown))
+}
+
+// MovePage is a no-op, documents are keyed by page ID and replaced by SavePage
+func (h *ElasticsearchHandler) MovePage(from, to models.Page) error {
+	return nil
+}
+
+// RemovePage appends a delete action for the page's document
+func (h *ElasticsearchHandler) RemovePage(page models.Page) error {
+	return h.writeLines(bulkAction{Delete: &bulkTarget{Index: h.index, ID: page.ID}})
+}
+
+// writeLines appends values to the bulk file, one JSON line each
+func (h *ElasticsearchHandler) writeLines(values ...any) error {
+	for _, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if _, err := h.writer.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Location describes where the bulk file was written and sent to
+func (h *ElasticsearchHandler) Location() string {
+	location := "Elasticsearch bulk file saved to " + h.path
+	if h.client != nil {
+		location += fmt.Sprintf(" and %d actions sent to %s", h.sent, h.client.BaseURL)
+	}
+	return location
+}
+
+// Close closes the bulk file and sends it to the cluster if one is configured
+func (h *ElasticsearchHandler) Close() error {
+	if h.writer == nil {
+		return nil
+	}
+	if err := h.writer.Close(); err != nil {
+		return err
+	}
+	h.writer = nil
+
+	if h.client == nil {
+		return nil
+	}
+	template, err := os.ReadFile(h.templatePath)
+	if err != nil {
+		return err
+	}
+	if err := h.client.PutIndexTemplate(h.index, template); err != nil {
+		return fmt.Errorf("failed to put index template: %v", err)
+	}
+	return h.send()
+}
+
+// send posts the bulk file in requests of at most batchSize actions and
+// maxBulkBytes bytes
+func (h *ElasticsearchHandler) send() error {
+	file, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var batch bytes.Buffer
+	actions := 0
+	flush := func() error {
+		if actions == 0 {
+			return nil
+		}
+		if err := h.client.Bulk(batch.Bytes()); err != nil {
+			return fmt.Errorf("failed to send bulk request: %v", err)
+		}
+		h.sent += actions
+		batch.Reset()
+		actions = 0
+		return nil
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			break
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		var action bulkAction
+		if err := json.Unmarshal(line, &action); err != nil {
+			return fmt.Errorf("invalid action in %s: %v", h.path, err)
+		}
+		// Index actions are followed by their document
+		if action.Index != nil {
+			source, err := reader.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			line = append(line, source...)
+		}
+
+		if actions > 0 && (actions >= h.batchSize || batch.Len()+len(line) > maxBulkBytes) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch.Write(line)
+		actions++
+	}
+	return flush()
+}
@@ -0,0 +1,147 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+)
+
+// esRequest is a request received by the Elasticsearch stand-in
+type esRequest struct {
+	method      string
+	path        string
+	contentType string
+	body        string
+}
+
+func TestElasticsearchHandlerSendsBulkRequests(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []esRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, esRequest{r.Method, r.URL.Path, r.Header.Get("Content-Type"), string(body)})
+		fmt.Fprint(w, `{"errors":false,"items":[]}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	handler, err := NewHandler(config.ExportConfig{
+		OutputType:    "elasticsearch",
+		OutputDir:     dir,
+		Elasticsearch: config.ElasticsearchConfig{URL: server.URL, Index: "wiki", BatchSize: 2},
+	})
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	if err := handler.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		page := models.Page{ID: fmt.Sprint(i), Title: fmt.Sprintf("Page %d", i), SpaceKey: "TEAM", Version: i, Labels: []models.Label{{Name: "ops"}}}
+		if err := handler.SavePage(page, fmt.Sprintf("Body %d", i)); err != nil {
+			t.Fatalf("SavePage: %v", err)
+		}
+	}
+	if err := handler.RemovePage(models.Page{ID: "9"}); err != nil {
+		t.Fatalf("RemovePage: %v", err)
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The template first, then the four actions in bulk requests of two
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3: %+v", len(requests), requests)
+	}
+	if r := requests[0]; r.method != "PUT" || r.path != "/_index_template/wiki" || r.contentType != "application/json" {
+		t.Errorf("first request = %s %s (%s), want the index template", r.method, r.path, r.contentType)
+	}
+	var sent strings.Builder
+	for _, r := range requests[1:] {
+		if r.method != "POST" || r.path != "/_bulk" || r.contentType != "application/x-ndjson" {
+			t.Errorf("request = %s %s (%s), want POST /_bulk as application/x-ndjson", r.method, r.path, r.contentType)
+		}
+		if !strings.HasSuffix(r.body, "\n") {
+			t.Errorf("bulk body %q doesn't end with a newline", r.body)
+		}
+		sent.WriteString(r.body)
+	}
+
+	wantLines := []string{
+		`{"index":{"_index":"wiki","_id":"1"}}`,
+		`{"title":"Page 1","body":"Body 1","url":"","space_key":"TEAM","version":1,"labels":["ops"]}`,
+		`{"index":{"_index":"wiki","_id":"2"}}`,
+		`{"title":"Page 2","body":"Body 2","url":"","space_key":"TEAM","version":2,"labels":["ops"]}`,
+		`{"index":{"_index":"wiki","_id":"3"}}`,
+		`{"title":"Page 3","body":"Body 3","url":"","space_key":"TEAM","version":3,"labels":["ops"]}`,
+		`{"delete":{"_index":"wiki","_id":"9"}}`,
+	}
+	want := strings.Join(wantLines, "\n") + "\n"
+	if got := requests[1].body; got != strings.Join(wantLines[:4], "\n")+"\n" {
+		t.Errorf("first bulk request = %q, want pages 1 and 2", got)
+	}
+	if sent.String() != want {
+		t.Errorf("sent %q, want %q", sent.String(), want)
+	}
+	file, err := os.ReadFile(filepath.Join(dir, ElasticsearchBulkFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(file) != want {
+		t.Errorf("bulk file = %q, want the actions that were sent", file)
+	}
+
+	var template map[string]any
+	if err := json.Unmarshal([]byte(requests[0].body), &template); err != nil {
+		t.Fatalf("index template isn't JSON: %v", err)
+	}
+	if patterns := fmt.Sprint(template["index_patterns"]); patterns != "[wiki]" {
+		t.Errorf("index_patterns = %s, want [wiki]", patterns)
+	}
+	if got := handler.(*ElasticsearchHandler).sent; got != 4 {
+		t.Errorf("sent %d actions, want 4", got)
+	}
+}
+
+func TestElasticsearchHandlerReportsBulkFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			fmt.Fprint(w, `{"errors":true,"items":[{"index":{"_id":"1","status":400,"error":{"type":"mapper_parsing_exception"}}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	}))
+	defer server.Close()
+
+	handler, err := NewHandler(config.ExportConfig{
+		OutputType:    "opensearch",
+		OutputDir:     t.TempDir(),
+		Elasticsearch: config.ElasticsearchConfig{URL: server.URL, Index: "wiki", BatchSize: 10},
+	})
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	if err := handler.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := handler.SavePage(models.Page{ID: "1", Title: "Home"}, "Body"); err != nil {
+		t.Fatalf("SavePage: %v", err)
+	}
+	if err := handler.Close(); err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("Close = %v, want the failed action", err)
+	}
+}
@@ -0,0 +1,172 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+)
+
+func init() {
+	Register("file", newFileHandler)
+}
+
+// FileHandler writes every page as an individual Markdown file
+type FileHandler struct {
+	outputDir   string
+	frontMatter *FrontMatter
+}
+
+func newFileHandler(cfg config.ExportConfig) (Handler, error) {
+	h := &FileHandler{outputDir: cfg.OutputDir}
+
+	if cfg.Format.IncludeFrontMatter {
+		frontMatter, err := NewFrontMatter(cfg.Format.FrontMatterFields, cfg.Format.FrontMatterFlavor)
+		if err != nil {
+			return nil, err
+		}
+		h.frontMatter = frontMatter
+	}
+
+	return h, nil
+}
+
+// PagePath returns the path of a page's Markdown file relative to the output directory
+func PagePath(page models.Page) string {
+	return filepath.Join(SafeFilename(page.SpaceKey), SafeFilename(page.Title)+".md")
+}
+
+// AttachmentPath returns the path of a page's attachment directory relative to
+// the output directory
+func AttachmentPath(page models.Page) string {
+	return filepath.Join(SafeFilename(page.SpaceKey), SafeFilename(page.Title)+"_attachments")
+}
+
+// VersionDir returns the path of the directory holding the historical
+// versions of a page relative to the output directory. A directory of its
+// own keeps versions from colliding with pages whose title looks like a
+// version file name.
+func VersionDir(page models.Page) string {
+	return filepath.Join(SafeFilename(page.SpaceKey), SafeFilename(page.Title)+".versions")
+}
+
+// VersionPath returns the path of the Markdown file of a historical version of
+// a page relative to the output directory
+func VersionPath(page models.Page, number int) string {
+	return filepath.Join(VersionDir(page), "v"+strconv.Itoa(number)+".md")
+}
+
+// Initialize creates the output directory
+func (h *FileHandler) Initialize() error {
+	return os.MkdirAll(h.outputDir, 0755)
+}
+
+// SavePage writes the page's Markdown to <outputDir>/<space>/<title>.md,
+// preceded by a YAML front matter block if enabled
+func (h *FileHandler) SavePage(page models.Page, markdown string) error {
+	return h.writePage(PagePath(page), page, markdown)
+}
+
+// SaveVersion writes a historical version of the page to
+// <outputDir>/<space>/<title>.versions/v<number>.md. The front matter
+// describes the version instead of the current page.
+func (h *FileHandler) SaveVersion(page models.Page, version models.PageVersion, markdown string) error {
+	return h.writePage(VersionPath(page, version.Number), versionPage(page, version), markdown)
+}
+
+// writePage writes markdown to path, preceded by the front matter of page
+func (h *FileHandler) writePage(path string, page models.Page, markdown string) error {
+	path = filepath.Join(h.outputDir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for page %s: %v", page.ID, err)
+	}
+
+	content := markdown + "\n"
+	if h.frontMatter != nil {
+		content = h.frontMatter.Render(page) + content
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write page %s: %v", page.ID, err)
+	}
+	return nil
+}
+
+// versionPage returns the metadata of page as of a historical version
+func versionPage(page models.Page, version models.PageVersion) models.Page {
+	if version.Title != "" {
+		page.Title = version.Title
+	}
+	page.Version = version.Number
+	page.Content = version.Content
+	page.UpdatedAt = version.CreatedAt
+	page.UpdatedBy = version.CreatedBy
+	page.VersionMessage = version.Message
+	return page
+}
+
+// MovePage moves the Markdown file, versions and attachments of a renamed or
+// moved page to its new location
+func (h *FileHandler) MovePage(from, to models.Page) error {
+	moves := [][2]string{
+		{filepath.Join(h.outputDir, PagePath(from)), filepath.Join(h.outputDir, PagePath(to))},
+		{h.AttachmentDir(from), h.AttachmentDir(to)},
+		{filepath.Join(h.outputDir, VersionDir(from)), filepath.Join(h.outputDir, VersionDir(to))},
+	}
+
+	for _, move := range moves {
+		if _, err := os.Stat(move[0]); errors.Is(err, os.ErrNotExist) || move[0] == move[1] {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(move[1]), 0755); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(move[1]); err != nil {
+			return err
+		}
+		if err := os.Rename(move[0], move[1]); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %v", move[0], move[1], err)
+		}
+	}
+	return nil
+}
+
+// RemovePage deletes the Markdown file, versions and attachments of a deleted page
+func (h *FileHandler) RemovePage(page models.Page) error {
+	if err := os.Remove(filepath.Join(h.outputDir, PagePath(page))); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(h.outputDir, VersionDir(page))); err != nil {
+		return err
+	}
+	return os.RemoveAll(h.AttachmentDir(page))
+}
+
+// AttachmentDir returns the directory attachments of the page are stored in
+func (h *FileHandler) AttachmentDir(page models.Page) string {
+	return filepath.Join(h.outputDir, AttachmentPath(page))
+}
+
+// PagePath returns the path of the page's Markdown file relative to the output directory
+func (h *FileHandler) PagePath(page models.Page) string {
+	return PagePath(page)
+}
+
+// VersionPath returns the path of a version's Markdown file relative to the output directory
+func (h *FileHandler) VersionPath(page models.Page, number int) string {
+	return VersionPath(page, number)
+}
+
+// Location describes where the Markdown files were written
+func (h *FileHandler) Location() string {
+	return "Files saved to " + h.outputDir
+}
+
+// Close is a no-op for the file handler
+func (h *FileHandler) Close() error {
+	return nil
+}
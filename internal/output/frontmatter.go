@@ -0,0 +1,227 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"confluence-exporter/internal/models"
+)
+
+// FrontMatterFields lists the supported front matter fields in their default order
+var FrontMatterFields = []string{
+	"id",
+	"title",
+	"space",
+	"version",
+	"source_url",
+	"parent_id",
+	"ancestors",
+	"labels",
+	"created_by",
+	"created_at",
+	"updated_by",
+	"updated_at",
+	"version_message",
+}
+
+// frontMatterFlavors maps field names to the keys expected by static site
+// generators. A dotted key is written as a nested map.
+var frontMatterFlavors = map[string]map[string]string{
+	"hugo": {
+		"labels":     "tags",
+		"created_by": "author",
+		"created_at": "date",
+		"updated_at": "lastmod",
+	},
+	"jekyll": {
+		"labels":     "tags",
+		"created_by": "author",
+		"created_at": "date",
+		"updated_at": "last_modified_at",
+	},
+	"docusaurus": {
+		// Docusaurus uses id for routing, keep the Confluence ID separate
+		"id":         "confluence_id",
+		"labels":     "tags",
+		"updated_by": "last_update.author",
+		"updated_at": "last_update.date",
+	},
+}
+
+// FrontMatter renders page metadata as a YAML front matter block
+type FrontMatter struct {
+	fields []string
+	keys   map[string]string
+}
+
+// NewFrontMatter creates a front matter renderer for the given fields (all
+// fields if empty) using the key names of flavor ("" for the plain names)
+func NewFrontMatter(fields []string, flavor string) (*FrontMatter, error) {
+	if len(fields) == 0 {
+		fields = FrontMatterFields
+	}
+	for _, field := range fields {
+		if !isFrontMatterField(field) {
+			return nil, fmt.Errorf("unknown front matter field %q (available: %s)", field, strings.Join(FrontMatterFields, ", "))
+		}
+	}
+
+	keys := map[string]string{}
+	if flavor != "" {
+		var ok bool
+		if keys, ok = frontMatterFlavors[flavor]; !ok {
+			return nil, fmt.Errorf("unknown front matter flavor %q (available: hugo, jekyll, docusaurus)", flavor)
+		}
+	}
+
+	return &FrontMatter{fields: fields, keys: keys}, nil
+}
+
+func isFrontMatterField(field string) bool {
+	for _, f := range FrontMatterFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Render returns the front matter block for page, including the --- delimiters
+func (f *FrontMatter) Render(page models.Page) string {
+	root := &yamlMap{}
+	for _, field := range f.fields {
+		value := frontMatterValue(page, field)
+		if value == nil {
+			continue
+		}
+
+		key := field
+		if mapped, ok := f.keys[field]; ok {
+			key = mapped
+		}
+		root.set(strings.Split(key, "."), value)
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	root.write(&b, 0)
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// frontMatterValue returns the value of field for page, or nil if it is empty
+func frontMatterValue(page models.Page, field string) any {
+	var value any
+	switch field {
+	case "id":
+		value = page.ID
+	case "title":
+		value = page.Title
+	case "space":
+		value = page.SpaceKey
+	case "version":
+		if page.Version > 0 {
+			return page.Version
+		}
+	case "source_url":
+		value = page.URL
+	case "parent_id":
+		value = page.ParentID
+	case "ancestors":
+		if len(page.Ancestors) > 0 {
+			return page.Ancestors
+		}
+	case "labels":
+		var labels []string
+		for _, label := range page.Labels {
+			labels = append(labels, label.Name)
+		}
+		if len(labels) > 0 {
+			return labels
+		}
+	case "created_by":
+		value = page.CreatedBy
+	case "created_at":
+		value = page.CreatedAt
+	case "updated_by":
+		value = page.UpdatedBy
+	case "updated_at":
+		value = page.UpdatedAt
+	case "version_message":
+		value = page.VersionMessage
+	}
+
+	if s, ok := value.(string); ok && s != "" {
+		return s
+	}
+	return nil
+}
+
+// yamlMap is an insertion-ordered YAML mapping
+type yamlMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// set stores value under the nested key path
+func (m *yamlMap) set(path []string, value any) {
+	if m.values == nil {
+		m.values = make(map[string]any)
+	}
+
+	key := path[0]
+	if _, exists := m.values[key]; !exists {
+		m.keys = append(m.keys, key)
+	}
+
+	if len(path) == 1 {
+		m.values[key] = value
+		return
+	}
+
+	child, ok := m.values[key].(*yamlMap)
+	if !ok {
+		child = &yamlMap{}
+		m.values[key] = child
+	}
+	child.set(path[1:], value)
+}
+
+// write renders the mapping at the given indentation level
+func (m *yamlMap) write(b *strings.Builder, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	for _, key := range m.keys {
+		switch value := m.values[key].(type) {
+		case *yamlMap:
+			b.WriteString(prefix + key + ":\n")
+			value.write(b, indent+1)
+		case []string:
+			b.WriteString(prefix + key + ":\n")
+			for _, item := range value {
+				b.WriteString(prefix + "  - " + yamlString(item) + "\n")
+			}
+		case []models.Ancestor:
+			b.WriteString(prefix + key + ":\n")
+			for _, ancestor := range value {
+				b.WriteString(prefix + "  - id: " + yamlString(ancestor.ID) + "\n")
+				b.WriteString(prefix + "    title: " + yamlString(ancestor.Title) + "\n")
+			}
+		case int:
+			b.WriteString(prefix + key + ": " + strconv.Itoa(value) + "\n")
+		case string:
+			b.WriteString(prefix + key + ": " + yamlString(value) + "\n")
+		}
+	}
+}
+
+// yamlString quotes s as a YAML double-quoted scalar
+func yamlString(s string) string {
+	// JSON strings are valid YAML double-quoted scalars
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
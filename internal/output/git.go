@@ -0,0 +1,247 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+	"confluence-exporter/internal/state"
+)
+
+// Commit trailers that record which page version a commit contains
+const (
+	gitPageTrailer    = "Confluence-Page"
+	gitVersionTrailer = "Confluence-Version"
+)
+
+// gitExporterName is the author of commits that have no Confluence author,
+// like the removal of deleted pages
+const gitExporterName = "Confluence Exporter"
+
+func init() {
+	Register("git", newGitHandler)
+}
+
+// GitHandler writes the Markdown files of the file handler into a git
+// repository and commits every page version with its Confluence author and
+// timestamp. The versions already committed are read from the commit
+// trailers, so later runs only add new commits.
+type GitHandler struct {
+	files *FileHandler
+	dir   string
+	// committed is the latest committed version by page ID
+	committed map[string]int
+}
+
+func newGitHandler(cfg config.ExportConfig) (Handler, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git output needs the git executable: %v", err)
+	}
+
+	files, err := newFileHandler(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &GitHandler{files: files.(*FileHandler), dir: cfg.OutputDir}, nil
+}
+
+// Initialize creates the repository if needed and reads the committed versions
+func (h *GitHandler) Initialize() error {
+	if err := h.files.Initialize(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(h.dir, ".git")); errors.Is(err, os.ErrNotExist) {
+		if _, err := h.git(nil, "init", "--quiet"); err != nil {
+			return err
+		}
+		// Keep the exporter's bookkeeping files out of the repository
+		exclude := "/" + state.FileName + "\n/" + state.CheckpointFileName + "\n"
+		if err := os.WriteFile(filepath.Join(h.dir, ".git", "info", "exclude"), []byte(exclude), 0644); err != nil {
+			return fmt.Errorf("failed to write git excludes: %v", err)
+		}
+	}
+
+	committed, err := h.committedVersions()
+	if err != nil {
+		return err
+	}
+	h.committed = committed
+	return nil
+}
+
+// committedVersions returns the latest committed version of every page
+func (h *GitHandler) committedVersions() (map[string]int, error) {
+	committed := make(map[string]int)
+	if _, err := h.git(nil, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		// No commits yet
+		return committed, nil
+	}
+
+	format := "--format=%(trailers:key=" + gitPageTrailer + ",valueonly,separator=)%x09%(trailers:key=" + gitVersionTrailer + ",valueonly,separator=)"
+	out, err := h.git(nil, "log", format)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		id, version, _ := strings.Cut(line, "\t")
+		number, err := strconv.Atoi(strings.TrimSpace(version))
+		if id = strings.TrimSpace(id); id == "" || err != nil {
+			continue
+		}
+		committed[id] = max(committed[id], number)
+	}
+	return committed, nil
+}
+
+// SaveVersion commits a historical version of the page, unless it or a later
+// version was committed before
+func (h *GitHandler) SaveVersion(page models.Page, version models.PageVersion, markdown string) error {
+	if version.Number <= h.committed[page.ID] {
+		return nil
+	}
+
+	versioned := versionPage(page, version)
+	if err := h.files.writePage(PagePath(page), versioned, markdown); err != nil {
+		return err
+	}
+	return h.commitPage(page, versioned)
+}
+
+// SavePage writes the page's Markdown file and commits it if it changed
+func (h *GitHandler) SavePage(page models.Page, markdown string) error {
+	if err := h.files.SavePage(page, markdown); err != nil {
+		return err
+	}
+	return h.commitPage(page, page)
+}
+
+// commitPage commits the file and attachments of page as the version
+// described by versioned
+func (h *GitHandler) commitPage(page, versioned models.Page) error {
+	message := fmt.Sprintf("%s (v%d)", versioned.Title, versioned.Version)
+	if versioned.VersionMessage != "" {
+		message += "\n\n" + versioned.VersionMessage
+	}
+	message += fmt.Sprintf("\n\n%s: %s\n%s: %d", gitPageTrailer, page.ID, gitVersionTrailer, versioned.Version)
+
+	paths := []string{PagePath(page), AttachmentPath(page)}
+	if err := h.commit(paths, message, versioned.UpdatedBy, versioned.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to commit page %s: %v", page.ID, err)
+	}
+	h.committed[page.ID] = max(h.committed[page.ID], versioned.Version)
+	return nil
+}
+
+// MovePage moves the page's files and commits the move
+func (h *GitHandler) MovePage(from, to models.Page) error {
+	if err := h.files.MovePage(from, to); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Move %s to %s\n\n%s: %s", from.Title, filepath.ToSlash(PagePath(to)), gitPageTrailer, to.ID)
+	paths := []string{PagePath(from), AttachmentPath(from), PagePath(to), AttachmentPath(to)}
+	if err := h.commit(paths, message, to.UpdatedBy, to.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to commit move of page %s: %v", to.ID, err)
+	}
+	return nil
+}
+
+// RemovePage deletes the page's files and commits the removal
+func (h *GitHandler) RemovePage(page models.Page) error {
+	if err := h.files.RemovePage(page); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Remove %s\n\n%s: %s", page.Title, gitPageTrailer, page.ID)
+	paths := []string{PagePath(page), AttachmentPath(page)}
+	if err := h.commit(paths, message, "", ""); err != nil {
+		return fmt.Errorf("failed to commit removal of page %s: %v", page.ID, err)
+	}
+	return nil
+}
+
+// commit stages the changes to paths and commits them as author at the given
+// Confluence timestamp. Nothing is committed if the paths didn't change. The
+// exporter and the current time are used when author or timestamp are unknown.
+func (h *GitHandler) commit(paths []string, message, author, timestamp string) error {
+	// Only stage the given paths, attachments of other pages may be
+	// downloading at the same time. Paths that neither exist nor are tracked
+	// would make git add fail.
+	var pathspecs []string
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(h.dir, path)); err == nil {
+			pathspecs = append(pathspecs, path)
+		} else if tracked, _ := h.git(nil, "ls-files", "--", path); tracked != "" {
+			pathspecs = append(pathspecs, path)
+		}
+	}
+	if len(pathspecs) == 0 {
+		return nil
+	}
+	if _, err := h.git(nil, append([]string{"add", "--all", "--"}, pathspecs...)...); err != nil {
+		return err
+	}
+	if _, err := h.git(nil, "diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+
+	if author == "" {
+		author = gitExporterName
+	}
+	when := time.Now()
+	if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		when = t
+	}
+	date := when.Format(time.RFC3339)
+
+	// The committer is the author as well, so the history doesn't depend on
+	// who ran the export
+	env := []string{
+		"GIT_AUTHOR_NAME=" + author, "GIT_AUTHOR_EMAIL=", "GIT_AUTHOR_DATE=" + date,
+		"GIT_COMMITTER_NAME=" + author, "GIT_COMMITTER_EMAIL=", "GIT_COMMITTER_DATE=" + date,
+	}
+	// Keep lines starting with # in titles and version messages
+	_, err := h.git(env, "-c", "commit.gpgsign=false", "commit", "--quiet", "--no-verify", "--cleanup=whitespace", "-m", message)
+	return err
+}
+
+// git runs a git command in the repository with additional environment variables
+func (h *GitHandler) git(env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = h.dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// AttachmentDir returns the directory attachments of the page are stored in
+func (h *GitHandler) AttachmentDir(page models.Page) string {
+	return h.files.AttachmentDir(page)
+}
+
+// PagePath returns the path of the page's Markdown file relative to the repository
+func (h *GitHandler) PagePath(page models.Page) string {
+	return PagePath(page)
+}
+
+// Location describes where the repository was written
+func (h *GitHandler) Location() string {
+	return "Git repository at " + h.dir
+}
+
+// Close is a no-op for the git handler
+func (h *GitHandler) Close() error {
+	return nil
+}
@@ -0,0 +1,114 @@
+package output
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+	"confluence-exporter/internal/state"
+)
+
+func newTestGitHandler(t *testing.T, dir string) *GitHandler {
+	handler, err := NewHandler(config.ExportConfig{OutputType: "git", OutputDir: dir})
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	if err := handler.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return handler.(*GitHandler)
+}
+
+// gitLog returns the commits of the repository in dir, oldest first, as
+// author, author date, page and version trailers and subject
+func gitLog(t *testing.T, dir string) []string {
+	format := "--format=%an|%aI|%(trailers:key=" + gitPageTrailer + ",valueonly,separator=)|%(trailers:key=" + gitVersionTrailer + ",valueonly,separator=)|%s"
+	cmd := exec.Command("git", "log", "--reverse", format)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+func TestGitHandlerCommitsPageVersions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	dir := t.TempDir()
+	home := models.Page{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 3, UpdatedBy: "carol", UpdatedAt: "2024-03-01T09:30:00Z"}
+	guide := models.Page{ID: "2", Title: "Guide", SpaceKey: "TEAM", Version: 1, UpdatedBy: "dave", UpdatedAt: "2024-03-02T12:00:00+02:00"}
+
+	h := newTestGitHandler(t, dir)
+	versions := []models.PageVersion{
+		{Number: 1, Title: "Welcome", CreatedBy: "alice", CreatedAt: "2024-01-01T10:00:00Z", Message: "First draft"},
+		{Number: 2, CreatedBy: "bob", CreatedAt: "2024-02-01T10:00:00Z"},
+	}
+	for _, version := range versions {
+		if err := h.SaveVersion(home, version, "# "+version.Title); err != nil {
+			t.Fatalf("SaveVersion: %v", err)
+		}
+	}
+	if err := h.SavePage(home, "# Home"); err != nil {
+		t.Fatalf("SavePage: %v", err)
+	}
+	if err := h.SavePage(guide, "# Guide"); err != nil {
+		t.Fatalf("SavePage: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, state.FileName), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"alice|2024-01-01T10:00:00+00:00|1|1|Welcome (v1)",
+		"bob|2024-02-01T10:00:00+00:00|1|2|Home (v2)",
+		"carol|2024-03-01T09:30:00+00:00|1|3|Home (v3)",
+		"dave|2024-03-02T12:00:00+02:00|2|1|Guide (v1)",
+	}
+	if got := gitLog(t, dir); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("git log =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A later run reads the committed versions from the trailers and only
+	// commits what changed
+	h = newTestGitHandler(t, dir)
+	if got := h.committed; got["1"] != 3 || got["2"] != 1 {
+		t.Errorf("committed versions = %v, want page 1 at 3 and page 2 at 1", got)
+	}
+	if err := h.SaveVersion(home, versions[1], "# Changed history"); err != nil {
+		t.Fatalf("SaveVersion: %v", err)
+	}
+	if err := h.SavePage(home, "# Home"); err != nil {
+		t.Fatalf("SavePage: %v", err)
+	}
+	if err := h.RemovePage(guide); err != nil {
+		t.Fatalf("RemovePage: %v", err)
+	}
+
+	log := gitLog(t, dir)
+	if len(log) != len(want)+1 {
+		t.Fatalf("git log has %d commits after the second run, want %d:\n%s", len(log), len(want)+1, strings.Join(log, "\n"))
+	}
+	if last := log[len(log)-1]; !strings.HasPrefix(last, gitExporterName+"|") || !strings.HasSuffix(last, "|2||Remove Guide") {
+		t.Errorf("last commit = %q, want the removal of page 2 by the exporter", last)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "TEAM", "Home.md")); err != nil || !strings.Contains(string(data), "# Home") {
+		t.Errorf("Home.md = %q, %v, want the current version", data, err)
+	}
+
+	// The exporter's state file stays out of the repository
+	cmd := exec.Command("git", "status", "--porcelain", "--ignored")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git status: %v", err)
+	}
+	if status := strings.TrimSpace(string(out)); status != "!! "+state.FileName {
+		t.Errorf("git status = %q, want only the ignored state file", status)
+	}
+}
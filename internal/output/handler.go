@@ -0,0 +1,146 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+)
+
+// Handler is an output sink for exported pages.
+//
+// The exporter calls Initialize once before the first page, SavePage for
+// every exported page and Close once at the end of the run. Handlers receive
+// the page metadata together with the already converted Markdown, so they
+// never need to talk to Confluence themselves.
+//
+// Pages that were moved or renamed since the previous run are passed to
+// MovePage before they are saved again, pages that were deleted in Confluence
+// are passed to RemovePage before Close.
+type Handler interface {
+	// Initialize prepares the sink (creates directories, opens files or databases).
+	Initialize() error
+	// SavePage stores a single page and its converted Markdown.
+	SavePage(page models.Page, markdown string) error
+	// MovePage relocates the artifacts of a page that was moved or renamed.
+	MovePage(from, to models.Page) error
+	// RemovePage removes the artifacts of a page that was deleted.
+	RemovePage(page models.Page) error
+	// Location describes where the exported data was written.
+	Location() string
+	// Close flushes pending data and releases resources.
+	Close() error
+}
+
+// AttachmentHandler is implemented by handlers that store page attachments
+// on disk. The exporter downloads attachments into AttachmentDir before
+// SavePage is called for the page.
+type AttachmentHandler interface {
+	AttachmentDir(page models.Page) string
+}
+
+// PathHandler is implemented by handlers that write one file per page. The
+// converter uses PagePath to turn links between exported pages into
+// relative links.
+type PathHandler interface {
+	// PagePath returns the path of the page's file relative to the output directory.
+	PagePath(page models.Page) string
+}
+
+// VersionPathHandler is implemented by handlers that write historical versions
+// to files of their own. The converter resolves relative links of a version
+// from VersionPath instead of PagePath.
+type VersionPathHandler interface {
+	// VersionPath returns the path of the version's file relative to the output directory.
+	VersionPath(page models.Page, number int) string
+}
+
+// VersionHandler is implemented by handlers that store the version history of
+// pages. With IncludeVersions, the exporter passes the historical versions of
+// a page to SaveVersion, oldest first, before the page itself is saved.
+// MovePage and RemovePage apply to the stored versions as well.
+type VersionHandler interface {
+	// SaveVersion stores a historical version of page and its converted Markdown.
+	SaveVersion(page models.Page, version models.PageVersion, markdown string) error
+}
+
+// Checkpointer is implemented by handlers that buffer output or rebuild it on
+// every run. It lets an interrupted export be resumed without losing or
+// duplicating output.
+type Checkpointer interface {
+	// Checkpoint flushes pending output and returns an opaque marker of the
+	// current output position.
+	Checkpoint() (string, error)
+	// Resume is called instead of Initialize when continuing an interrupted
+	// run. Output written after marker is discarded.
+	Resume(marker string) error
+}
+
+// Factory creates a handler from the export configuration
+type Factory func(cfg config.ExportConfig) (Handler, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes an output handler available under the given output type name.
+// It panics if the name is empty or already registered.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" || factory == nil {
+		panic("output: Register called with empty name or nil factory")
+	}
+	if _, exists := registry[name]; exists {
+		panic("output: Register called twice for handler " + name)
+	}
+	registry[name] = factory
+}
+
+// Names returns the sorted list of registered output types
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewHandler creates the handler registered for cfg.OutputType
+func NewHandler(cfg config.ExportConfig) (Handler, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.OutputType]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown output type %q (available: %s)", cfg.OutputType, strings.Join(Names(), ", "))
+	}
+	return factory(cfg)
+}
+
+// SafeFilename converts a string to a safe filename
+func SafeFilename(name string) string {
+	// Replace characters that are not allowed in filenames
+	replacer := strings.NewReplacer(
+		"/", "-",
+		"\\", "-",
+		":", "-",
+		"*", "-",
+		"?", "-",
+		"\"", "-",
+		"<", "-",
+		">", "-",
+		"|", "-",
+		" ", "_",
+	)
+	return replacer.Replace(name)
+}
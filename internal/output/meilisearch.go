@@ -0,0 +1,203 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+)
+
+// MeiliSearchFile is the JSON file written by the meilisearch handler
+const MeiliSearchFile = "confluence_pages_meilisearch.json"
+
+// MeiliSearchDeletesFile lists the UIDs of deleted pages, in the format
+// expected by MeiliSearch's delete-batch endpoint
+const MeiliSearchDeletesFile = "confluence_pages_meilisearch_deletes.json"
+
+func init() {
+	Register("meilisearch", newMeiliSearchHandler)
+}
+
+// MeiliSearchDocument is a single page as indexed by MeiliSearch
+type MeiliSearchDocument struct {
+	UID       string   `json:"uid"`
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	Link      string   `json:"link"`
+	SpaceKey  string   `json:"spaceKey"`
+	ParentID  string   `json:"parentId,omitempty"`
+	Version   int      `json:"version"`
+	CreatedAt string   `json:"createdAt,omitempty"`
+	UpdatedAt string   `json:"updatedAt,omitempty"`
+	CreatedBy string   `json:"createdBy,omitempty"`
+	UpdatedBy string   `json:"updatedBy,omitempty"`
+	Labels    []string `json:"labels,omitempty"`
+}
+
+// MeiliSearchHandler collects all pages and writes them as one JSON array.
+// Documents are spooled to a temporary file while the export runs, so an
+// interrupted export can be resumed.
+type MeiliSearchHandler struct {
+	path        string
+	deletesPath string
+	spool       *appendFile
+	deletes     []string
+}
+
+// newMeiliSearchHandler indexes pages in MeiliSearch if a URL is configured
+// and writes a JSON file otherwise
+func newMeiliSearchHandler(cfg config.ExportConfig) (Handler, error) {
+	if cfg.MeiliSearch.URL != "" {
+		return newMeiliSearchIndexHandler(cfg.MeiliSearch), nil
+	}
+	return &MeiliSearchHandler{
+		path:        filepath.Join(cfg.OutputDir, MeiliSearchFile),
+		deletesPath: filepath.Join(cfg.OutputDir, MeiliSearchDeletesFile),
+	}, nil
+}
+
+// newMeiliSearchDocument builds the MeiliSearch document for a page
+func newMeiliSearchDocument(page models.Page, markdown string) MeiliSearchDocument {
+	doc := MeiliSearchDocument{
+		UID:       page.ID,
+		Title:     page.Title,
+		Body:      markdown,
+		Link:      page.URL,
+		SpaceKey:  page.SpaceKey,
+		ParentID:  page.ParentID,
+		Version:   page.Version,
+		CreatedAt: page.CreatedAt,
+		UpdatedAt: page.UpdatedAt,
+		CreatedBy: page.CreatedBy,
+		UpdatedBy: page.UpdatedBy,
+	}
+	for _, label := range page.Labels {
+		doc.Labels = append(doc.Labels, label.Name)
+	}
+	return doc
+}
+
+// spoolPath is the temporary file documents are collected in
+func (h *MeiliSearchHandler) spoolPath() string {
+	return h.path + ".partial"
+}
+
+// Initialize creates the document spool file
+func (h *MeiliSearchHandler) Initialize() error {
+	spool, err := createAppendFile(h.spoolPath())
+	if err != nil {
+		return err
+	}
+	h.spool = spool
+	return nil
+}
+
+// Resume reopens the spool file of an interrupted run
+func (h *MeiliSearchHandler) Resume(marker string) error {
+	spool, err := openAppendFile(h.spoolPath(), marker)
+	if err != nil {
+		return err
+	}
+	h.spool = spool
+	return nil
+}
+
+// Checkpoint flushes the spooled documents to disk
+func (h *MeiliSearchHandler) Checkpoint() (string, error) {
+	return h.spool.Checkpoint()
+}
+
+// SavePage adds the page to the spooled documents
+func (h *MeiliSearchHandler) SavePage(page models.Page, markdown string) error {
+	data, err := json.Marshal(newMeiliSearchDocument(page, markdown))
+	if err != nil {
+		return err
+	}
+	_, err = h.spool.Write(append(data, '\n'))
+	return err
+}
+
+// MovePage is a no-op, documents are keyed by page ID and replaced by SavePage
+func (h *MeiliSearchHandler) MovePage(from, to models.Page) error {
+	return nil
+}
+
+// RemovePage adds the page to the list of documents to delete
+func (h *MeiliSearchHandler) RemovePage(page models.Page) error {
+	h.deletes = append(h.deletes, page.ID)
+	return nil
+}
+
+// Location describes where the JSON file was written
+func (h *MeiliSearchHandler) Location() string {
+	return "MeiliSearch JSON saved to " + h.path
+}
+
+// readSpool reads all spooled documents
+func (h *MeiliSearchHandler) readSpool() ([]MeiliSearchDocument, error) {
+	file, err := os.Open(h.spoolPath())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	documents := []MeiliSearchDocument{}
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var doc MeiliSearchDocument
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to read spooled MeiliSearch documents: %v", err)
+		}
+		documents = append(documents, doc)
+	}
+	return documents, nil
+}
+
+// Close writes all collected documents to the JSON file
+func (h *MeiliSearchHandler) Close() error {
+	if h.spool == nil {
+		return nil
+	}
+	if err := h.spool.Close(); err != nil {
+		return err
+	}
+	h.spool = nil
+
+	documents, err := h.readSpool()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(documents, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode MeiliSearch documents: %v", err)
+	}
+
+	if err := os.WriteFile(h.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", h.path, err)
+	}
+	if err := os.Remove(h.spoolPath()); err != nil {
+		return err
+	}
+
+	// Only keep a deletes file around when this run removed pages
+	if len(h.deletes) == 0 {
+		if err := os.Remove(h.deletesPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	data, err = json.MarshalIndent(h.deletes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode MeiliSearch deletes: %v", err)
+	}
+	if err := os.WriteFile(h.deletesPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", h.deletesPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package output
+
+import (
+	"fmt"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/meilisearch"
+	"confluence-exporter/internal/models"
+)
+
+// meiliSearchPrimaryKey is the document field MeiliSearch identifies pages by
+const meiliSearchPrimaryKey = "uid"
+
+// meiliSearchSettings are applied to the index on every run
+var meiliSearchSettings = meilisearch.Settings{
+	SearchableAttributes: []string{"title", "body", "labels"},
+	FilterableAttributes: []string{"spaceKey", "labels", "updatedAt"},
+	SortableAttributes:   []string{"updatedAt"},
+}
+
+// MeiliSearchIndexHandler sends pages straight to a MeiliSearch index. The
+// documents are the same as in the JSON file of MeiliSearchHandler and are
+// sent in batches; deleted pages are removed from the index on Close.
+type MeiliSearchIndexHandler struct {
+	client    *meilisearch.Client
+	url       string
+	index     string
+	batchSize int
+
+	batch   []MeiliSearchDocument
+	deletes []string
+	// tasks are the enqueued tasks not known to have finished yet
+	tasks   []int64
+	indexed int
+}
+
+func newMeiliSearchIndexHandler(cfg config.MeiliSearchConfig) *MeiliSearchIndexHandler {
+	return &MeiliSearchIndexHandler{
+		client:    meilisearch.NewClient(cfg.URL, cfg.APIKey),
+		url:       cfg.URL,
+		index:     cfg.Index,
+		batchSize: cfg.BatchSize,
+	}
+}
+
+// Initialize creates the index if needed and configures its attributes
+func (h *MeiliSearchIndexHandler) Initialize() error {
+	if err := h.client.EnsureIndex(h.index, meiliSearchPrimaryKey); err != nil {
+		return fmt.Errorf("failed to create MeiliSearch index %s: %v", h.index, err)
+	}
+
+	task, err := h.client.UpdateSettings(h.index, meiliSearchSettings)
+	if err != nil {
+		return fmt.Errorf("failed to update MeiliSearch index settings: %v", err)
+	}
+	if err := h.client.WaitTask(task); err != nil {
+		return fmt.Errorf("failed to update MeiliSearch index settings: %v", err)
+	}
+	return nil
+}
+
+// Resume initializes the handler again. Documents are replaced by their ID,
+// so pages sent again after an interruption are not duplicated.
+func (h *MeiliSearchIndexHandler) Resume(marker string) error {
+	return h.Initialize()
+}
+
+// Checkpoint sends the pending batch and waits until MeiliSearch has indexed
+// all documents sent so far
+func (h *MeiliSearchIndexHandler) Checkpoint() (string, error) {
+	if err := h.flush(); err != nil {
+		return "", err
+	}
+	return "", h.wait()
+}
+
+// SavePage adds the page to the current batch and sends the batch once it is full
+func (h *MeiliSearchIndexHandler) SavePage(page models.Page, markdown string) error {
+	h.batch = append(h.batch, newMeiliSearchDocument(page, markdown))
+	if len(h.batch) < h.batchSize {
+		return nil
+	}
+	return h.flush()
+}
+
+// flush sends the documents of the current batch
+func (h *MeiliSearchIndexHandler) flush() error {
+	if len(h.batch) == 0 {
+		return nil
+	}
+
+	task, err := h.client.AddDocuments(h.index, meiliSearchPrimaryKey, h.batch)
+	if err != nil {
+		return fmt.Errorf("failed to send documents to MeiliSearch: %v", err)
+	}
+	h.tasks = append(h.tasks, task)
+	h.indexed += len(h.batch)
+	h.batch = nil
+	return nil
+}
+
+// wait polls the pending tasks until they are finished
+func (h *MeiliSearchIndexHandler) wait() error {
+	for len(h.tasks) > 0 {
+		if err := h.client.WaitTask(h.tasks[0]); err != nil {
+			return err
+		}
+		h.tasks = h.tasks[1:]
+	}
+	return nil
+}
+
+// MovePage is a no-op, documents are keyed by page ID and replaced by SavePage
+func (h *MeiliSearchIndexHandler) MovePage(from, to models.Page) error {
+	return nil
+}
+
+// RemovePage adds the page to the documents deleted on Close
+func (h *MeiliSearchIndexHandler) RemovePage(page models.Page) error {
+	h.deletes = append(h.deletes, page.ID)
+	return nil
+}
+
+// Location describes the index the pages were sent to
+func (h *MeiliSearchIndexHandler) Location() string {
+	return fmt.Sprintf("%d documents indexed in MeiliSearch index %s at %s", h.indexed, h.index, h.url)
+}
+
+// Close sends the last batch and the deletions and waits until MeiliSearch
+// has processed them
+func (h *MeiliSearchIndexHandler) Close() error {
+	if err := h.flush(); err != nil {
+		return err
+	}
+
+	if len(h.deletes) > 0 {
+		task, err := h.client.DeleteDocuments(h.index, h.deletes)
+		if err != nil {
+			return fmt.Errorf("failed to delete documents from MeiliSearch: %v", err)
+		}
+		h.tasks = append(h.tasks, task)
+		h.deletes = nil
+	}
+
+	return h.wait()
+}
@@ -0,0 +1,109 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/meilisearch/meilisearchtest"
+	"confluence-exporter/internal/models"
+)
+
+func newTestMeiliSearchIndexHandler(t *testing.T, batchSize int) (*MeiliSearchIndexHandler, *meilisearchtest.Server) {
+	server := meilisearchtest.NewServer(t, "")
+	server.AddIndex("confluence")
+
+	h := newMeiliSearchIndexHandler(config.MeiliSearchConfig{URL: server.URL, Index: "confluence", BatchSize: batchSize})
+	h.client.PollInterval = time.Millisecond
+	if err := h.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return h, server
+}
+
+// sentBatches returns the document batches the server received
+func sentBatches(t *testing.T, server *meilisearchtest.Server) [][]MeiliSearchDocument {
+	var batches [][]MeiliSearchDocument
+	for _, body := range server.Bodies("POST /indexes/confluence/documents") {
+		var docs []MeiliSearchDocument
+		if err := json.Unmarshal([]byte(body), &docs); err != nil {
+			t.Fatalf("invalid documents %s: %v", body, err)
+		}
+		batches = append(batches, docs)
+	}
+	return batches
+}
+
+// waitedTasks returns the IDs of the tasks that were polled, in order
+func waitedTasks(server *meilisearchtest.Server) []string {
+	var waited []string
+	for _, request := range server.Requests() {
+		if id, ok := strings.CutPrefix(request, "GET /tasks/"); ok {
+			waited = append(waited, id)
+		}
+	}
+	return waited
+}
+
+func TestMeiliSearchIndexHandlerBatches(t *testing.T) {
+	h, server := newTestMeiliSearchIndexHandler(t, 2)
+
+	for i := 1; i <= 5; i++ {
+		page := models.Page{ID: fmt.Sprint(i), Title: fmt.Sprintf("Page %d", i), SpaceKey: "TEAM"}
+		if err := h.SavePage(page, "body"); err != nil {
+			t.Fatalf("SavePage: %v", err)
+		}
+	}
+	if got := len(sentBatches(t, server)); got != 2 {
+		t.Fatalf("batches sent before Close = %d, want 2", got)
+	}
+
+	if err := h.RemovePage(models.Page{ID: "9"}); err != nil {
+		t.Fatalf("RemovePage: %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	batches := sentBatches(t, server)
+	var sizes []int
+	for _, batch := range batches {
+		sizes = append(sizes, len(batch))
+	}
+	if fmt.Sprint(sizes) != "[2 2 1]" {
+		t.Errorf("batch sizes = %v, want [2 2 1]", sizes)
+	}
+	if got := batches[2][0]; got.UID != "5" || got.Title != "Page 5" || got.SpaceKey != "TEAM" {
+		t.Errorf("last document = %+v", got)
+	}
+	if deletes := server.Bodies("POST /indexes/confluence/documents/delete-batch"); fmt.Sprint(deletes) != `[["9"]]` {
+		t.Errorf("deletes = %v, want [[\"9\"]]", deletes)
+	}
+	// Settings, three batches and the deletion are waited for
+	if waited := waitedTasks(server); fmt.Sprint(waited) != "[1 2 3 4 5]" {
+		t.Errorf("waited for tasks %v, want [1 2 3 4 5]", waited)
+	}
+	if got := h.Location(); !strings.HasPrefix(got, "5 documents indexed") {
+		t.Errorf("Location = %q", got)
+	}
+}
+
+func TestMeiliSearchIndexHandlerCheckpointFlushes(t *testing.T) {
+	h, server := newTestMeiliSearchIndexHandler(t, 10)
+
+	if err := h.SavePage(models.Page{ID: "1"}, "body"); err != nil {
+		t.Fatalf("SavePage: %v", err)
+	}
+	if len(sentBatches(t, server)) != 0 {
+		t.Fatalf("a partial batch was sent before the checkpoint")
+	}
+	if _, err := h.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if batches := sentBatches(t, server); len(batches) != 1 || len(h.tasks) != 0 {
+		t.Errorf("Checkpoint sent %d batches and left %d tasks pending, want 1 and 0", len(batches), len(h.tasks))
+	}
+}
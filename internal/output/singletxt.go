@@ -0,0 +1,108 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"confluence-exporter/internal/config"
+	"confluence-exporter/internal/models"
+)
+
+// SingleTextFile is the text file written by the singletxt handler
+const SingleTextFile = "confluence_export.txt"
+
+func init() {
+	Register("singletxt", newSingleTextHandler)
+}
+
+// SingleTextHandler appends all pages to one text file with a metadata header per page
+type SingleTextHandler struct {
+	path   string
+	writer *appendFile
+}
+
+func newSingleTextHandler(cfg config.ExportConfig) (Handler, error) {
+	return &SingleTextHandler{path: filepath.Join(cfg.OutputDir, SingleTextFile)}, nil
+}
+
+// Initialize creates the output file
+func (h *SingleTextHandler) Initialize() error {
+	writer, err := createAppendFile(h.path)
+	if err != nil {
+		return err
+	}
+	h.writer = writer
+	return nil
+}
+
+// Resume reopens the output file of an interrupted run
+func (h *SingleTextHandler) Resume(marker string) error {
+	writer, err := openAppendFile(h.path, marker)
+	if err != nil {
+		return err
+	}
+	h.writer = writer
+	return nil
+}
+
+// Checkpoint flushes the text file to disk
+func (h *SingleTextHandler) Checkpoint() (string, error) {
+	return h.writer.Checkpoint()
+}
+
+// SavePage appends the page header and Markdown body to the text file
+func (h *SingleTextHandler) SavePage(page models.Page, markdown string) error {
+	separator := strings.Repeat("=", 80)
+
+	var labels []string
+	for _, label := range page.Labels {
+		labels = append(labels, label.Name)
+	}
+
+	fmt.Fprintln(h.writer, separator)
+	fmt.Fprintf(h.writer, "Title: %s\n", page.Title)
+	fmt.Fprintf(h.writer, "Space: %s\n", page.SpaceKey)
+	fmt.Fprintf(h.writer, "Link: %s\n", page.URL)
+	fmt.Fprintf(h.writer, "Created: %s by %s\n", page.CreatedAt, page.CreatedBy)
+	fmt.Fprintf(h.writer, "Updated: %s by %s\n", page.UpdatedAt, page.UpdatedBy)
+	fmt.Fprintf(h.writer, "Labels: %s\n", strings.Join(labels, ", "))
+	fmt.Fprintln(h.writer, separator)
+	fmt.Fprintln(h.writer)
+	fmt.Fprintln(h.writer, markdown)
+	_, err := fmt.Fprintln(h.writer)
+	return err
+}
+
+// MovePage is a no-op, the text file is rewritten on every run
+func (h *SingleTextHandler) MovePage(from, to models.Page) error {
+	return nil
+}
+
+// RemovePage appends a deletion record, so incremental exports show removed pages
+func (h *SingleTextHandler) RemovePage(page models.Page) error {
+	separator := strings.Repeat("=", 80)
+
+	fmt.Fprintln(h.writer, separator)
+	fmt.Fprintf(h.writer, "Deleted: %s\n", page.Title)
+	fmt.Fprintf(h.writer, "Space: %s\n", page.SpaceKey)
+	fmt.Fprintf(h.writer, "ID: %s\n", page.ID)
+	fmt.Fprintln(h.writer, separator)
+	_, err := fmt.Fprintln(h.writer)
+	return err
+}
+
+// Location describes where the text file was written
+func (h *SingleTextHandler) Location() string {
+	return "Single text file saved to " + h.path
+}
+
+// Close flushes and closes the text file
+func (h *SingleTextHandler) Close() error {
+	if h.writer == nil {
+		return nil
+	}
+	err := h.writer.Close()
+	h.writer = nil
+	return err
+}
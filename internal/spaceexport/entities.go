@@ -0,0 +1,235 @@
+package spaceexport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// object is an <object> of entities.xml, a Hibernate entity of Confluence
+type object struct {
+	Class      string     `xml:"class,attr"`
+	ID         string     `xml:"id"`
+	Properties []property `xml:"property"`
+}
+
+// property is a value or a reference to another object
+type property struct {
+	Name string `xml:"name,attr"`
+	// ID is the ID of the referenced object
+	ID    string `xml:"id"`
+	Value string `xml:",chardata"`
+}
+
+// get returns the value of the property with the given name
+func (o *object) get(name string) string {
+	for _, p := range o.Properties {
+		if p.Name == name {
+			return strings.TrimSpace(p.Value)
+		}
+	}
+	return ""
+}
+
+// ref returns the ID of the object the property with the given name refers to
+func (o *object) ref(name string) string {
+	for _, p := range o.Properties {
+		if p.Name == name {
+			return strings.TrimSpace(p.ID)
+		}
+	}
+	return ""
+}
+
+// number returns the integer value of a property, 0 if it isn't set
+func (o *object) number(name string) int64 {
+	n, _ := strconv.ParseInt(o.get(name), 10, 64)
+	return n
+}
+
+// Content states of Confluence, only current content is exported
+const statusCurrent = "current"
+
+// content is a page, a historical version of a page or an attachment
+type content struct {
+	id       string
+	title    string
+	version  int
+	status   string
+	spaceID  string
+	parentID string
+	// originalID is the current page or attachment of a historical version
+	originalID string
+	// containerID is the page an attachment belongs to
+	containerID string
+	position    int64
+	creator     string
+	created     string
+	modifier    string
+	modified    string
+	comment     string
+	// mediaType and fileSize are set on attachments of older exports, newer
+	// ones store them as content properties
+	mediaType string
+	fileSize  int64
+}
+
+// current reports whether the content is the current version of live content
+func (c *content) current() bool {
+	return c.originalID == "" && (c.status == "" || c.status == statusCurrent)
+}
+
+// entities holds the objects of entities.xml the exporter needs
+type entities struct {
+	spaces map[string]spaceEntity
+	// users maps user keys to user names
+	users       map[string]string
+	pages       map[string]*content
+	attachments map[string]*content
+	// bodies maps content IDs to their storage format body
+	bodies map[string]string
+	labels map[string]string
+	// labellings maps content IDs to label IDs
+	labellings map[string][]string
+	// properties maps content IDs to their content properties by name
+	properties map[string]map[string]string
+}
+
+// spaceEntity is a space of the export
+type spaceEntity struct {
+	key  string
+	name string
+}
+
+// Body types of BodyContent, the exporter converts storage format only
+const bodyTypeStorage = "2"
+
+// parseEntities reads entities.xml object by object
+func parseEntities(r io.Reader) (*entities, error) {
+	e := &entities{
+		spaces:      make(map[string]spaceEntity),
+		users:       make(map[string]string),
+		pages:       make(map[string]*content),
+		attachments: make(map[string]*content),
+		bodies:      make(map[string]string),
+		labels:      make(map[string]string),
+		labellings:  make(map[string][]string),
+		properties:  make(map[string]map[string]string),
+	}
+
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse entities.xml: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "object" {
+			continue
+		}
+
+		var o object
+		if err := decoder.DecodeElement(&o, &start); err != nil {
+			return nil, fmt.Errorf("failed to parse entities.xml: %v", err)
+		}
+		o.ID = strings.TrimSpace(o.ID)
+		e.add(&o)
+	}
+	return e, nil
+}
+
+// add records an object if it is of a class the exporter needs
+func (e *entities) add(o *object) {
+	switch o.Class {
+	case "Space":
+		e.spaces[o.ID] = spaceEntity{key: o.get("key"), name: o.get("name")}
+	case "ConfluenceUserImpl":
+		e.users[o.ID] = o.get("name")
+	case "Page":
+		e.pages[o.ID] = newContent(o)
+	case "Attachment":
+		e.attachments[o.ID] = newContent(o)
+	case "BodyContent":
+		if bodyType := o.get("bodyType"); bodyType == "" || bodyType == bodyTypeStorage {
+			e.bodies[o.ref("content")] = o.get("body")
+		}
+	case "Label":
+		// Personal labels are not shown on pages
+		if namespace := o.get("namespace"); namespace != "my" {
+			e.labels[o.ID] = o.get("name")
+		}
+	case "Labelling":
+		id := o.ref("content")
+		e.labellings[id] = append(e.labellings[id], o.ref("label"))
+	case "ContentProperty":
+		id := o.ref("content")
+		if e.properties[id] == nil {
+			e.properties[id] = make(map[string]string)
+		}
+		value := o.get("stringValue")
+		if value == "" {
+			value = o.get("longValue")
+		}
+		e.properties[id][o.get("name")] = value
+	}
+}
+
+// newContent reads the fields shared by pages and attachments
+func newContent(o *object) *content {
+	c := &content{
+		id:          o.ID,
+		title:       o.get("title"),
+		version:     int(o.number("version")),
+		status:      o.get("contentStatus"),
+		spaceID:     o.ref("space"),
+		parentID:    o.ref("parent"),
+		originalID:  o.ref("originalVersion"),
+		containerID: o.ref("containerContent"),
+		position:    o.number("position"),
+		creator:     o.ref("creator"),
+		created:     timestamp(o.get("creationDate")),
+		modifier:    o.ref("lastModifier"),
+		modified:    timestamp(o.get("lastModificationDate")),
+		comment:     o.get("versionComment"),
+		mediaType:   o.get("contentType"),
+		fileSize:    o.number("fileSize"),
+	}
+
+	// Older exports name users directly and attach attachments as content
+	if c.creator == "" {
+		c.creator = "name:" + o.get("creatorName")
+	}
+	if c.modifier == "" {
+		c.modifier = "name:" + o.get("lastModifierName")
+	}
+	if c.containerID == "" {
+		c.containerID = o.ref("content")
+	}
+	return c
+}
+
+// user returns the name of a user referenced by a content object
+func (e *entities) user(ref string) string {
+	if name, ok := strings.CutPrefix(ref, "name:"); ok {
+		return name
+	}
+	return e.users[ref]
+}
+
+// timestamp converts a date of entities.xml, which has no time zone, to the
+// RFC 3339 format of the REST API. The server's time zone is unknown, so UTC
+// is assumed.
+func timestamp(s string) string {
+	for _, layout := range []string{"2006-01-02 15:04:05.000", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,344 @@
+package spaceexport
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"confluence-exporter/internal/models"
+)
+
+// ErrSearchUnsupported is returned for CQL queries, which only Confluence can
+// evaluate
+var ErrSearchUnsupported = errors.New("CQL queries need the Confluence REST API and can't be run on a space export")
+
+// Source reads pages from a Confluence space export ZIP instead of the REST
+// API. The ZIP contains entities.xml, a dump of the space's database objects,
+// and the files of all attachments in attachments/<page ID>/<attachment ID>/.
+type Source struct {
+	baseURL  string
+	archive  *zip.ReadCloser
+	entities *entities
+	// children maps page IDs to their child pages in Confluence's order
+	children map[string][]*content
+	// history maps page IDs to their historical versions
+	history map[string][]*content
+	// attachments maps page IDs to their current attachments
+	attachments map[string][]*content
+	// files maps attachment IDs to their file in the ZIP
+	files map[string]*zip.File
+}
+
+// Open reads the space export ZIP at path. baseURL is the address of the
+// Confluence instance the space was exported from, used for the URLs of pages
+// and attachments; it may be empty.
+func Open(path, baseURL string) (*Source, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open space export %s: %v", path, err)
+	}
+
+	s := &Source{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		archive:     archive,
+		children:    make(map[string][]*content),
+		history:     make(map[string][]*content),
+		attachments: make(map[string][]*content),
+		files:       make(map[string]*zip.File),
+	}
+	if err := s.load(); err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("failed to read space export %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// load parses entities.xml and indexes the pages and attachments
+func (s *Source) load() error {
+	var entitiesFile *zip.File
+	files := make(map[string]*zip.File, len(s.archive.File))
+	for _, f := range s.archive.File {
+		files[f.Name] = f
+		if f.Name == "entities.xml" {
+			entitiesFile = f
+		}
+	}
+	if entitiesFile == nil {
+		return fmt.Errorf("entities.xml not found, is this a Confluence space export?")
+	}
+
+	r, err := entitiesFile.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if s.entities, err = parseEntities(r); err != nil {
+		return err
+	}
+
+	for _, page := range s.entities.pages {
+		switch {
+		case page.originalID != "":
+			s.history[page.originalID] = append(s.history[page.originalID], page)
+		case page.current():
+			if parent := s.entities.pages[page.parentID]; parent != nil && parent.current() {
+				s.children[page.parentID] = append(s.children[page.parentID], page)
+			} else {
+				s.children[""] = append(s.children[""], page)
+			}
+		}
+	}
+	for _, pages := range s.children {
+		sortPages(pages)
+	}
+
+	for _, attachment := range s.entities.attachments {
+		if !attachment.current() {
+			continue
+		}
+		s.attachments[attachment.containerID] = append(s.attachments[attachment.containerID], attachment)
+
+		// Attachment files are named after their version, some older exports
+		// store the file without a version directory
+		dir := path.Join("attachments", attachment.containerID, attachment.id)
+		if f := files[path.Join(dir, strconv.Itoa(attachment.version))]; f != nil {
+			s.files[attachment.id] = f
+		} else if f := files[dir]; f != nil {
+			s.files[attachment.id] = f
+		}
+	}
+	for _, attachments := range s.attachments {
+		sort.Slice(attachments, func(i, j int) bool { return attachments[i].title < attachments[j].title })
+	}
+	return nil
+}
+
+// sortPages sorts sibling pages like Confluence's page tree
+func sortPages(pages []*content) {
+	sort.Slice(pages, func(i, j int) bool {
+		if pages[i].position != pages[j].position {
+			return pages[i].position < pages[j].position
+		}
+		return pages[i].title < pages[j].title
+	})
+}
+
+// Close closes the ZIP file
+func (s *Source) Close() error {
+	return s.archive.Close()
+}
+
+// GetBaseURL returns the base URL of the Confluence instance the space was
+// exported from
+func (s *Source) GetBaseURL() string {
+	return s.baseURL
+}
+
+// GetSpaces returns the spaces of the export
+func (s *Source) GetSpaces() ([]models.Space, error) {
+	var spaces []models.Space
+	for _, space := range s.entities.spaces {
+		spaces = append(spaces, models.Space{Key: space.key, Name: space.name, Type: "global"})
+	}
+	sort.Slice(spaces, func(i, j int) bool { return spaces[i].Key < spaces[j].Key })
+	return spaces, nil
+}
+
+// spacePages returns the pages of a space, every page followed by its
+// descendants
+func (s *Source) spacePages(spaceKey string) []models.Page {
+	var pages []models.Page
+	var walk func(parentID string)
+	walk = func(parentID string) {
+		for _, page := range s.children[parentID] {
+			if parentID == "" && s.entities.spaces[page.spaceID].key != spaceKey {
+				continue
+			}
+			pages = append(pages, s.toPage(page))
+			walk(page.id)
+		}
+	}
+	walk("")
+	return pages
+}
+
+// ListPages calls fn once with the pages of a space from the offset start on
+func (s *Source) ListPages(spaceKey string, start int, fn func(pages []models.Page, next int) error) error {
+	pages := s.spacePages(spaceKey)
+	if start > len(pages) {
+		start = len(pages)
+	}
+	return fn(pages[start:], len(pages))
+}
+
+// ListPageSummaries returns the pages of a space
+func (s *Source) ListPageSummaries(spaceKey string) ([]models.Page, error) {
+	return s.spacePages(spaceKey), nil
+}
+
+// SearchPages fails, CQL queries can't be run on a space export
+func (s *Source) SearchPages(cql string, start int, fn func(pages []models.Page, next int) error) error {
+	return ErrSearchUnsupported
+}
+
+// SearchPageSummaries fails, CQL queries can't be run on a space export
+func (s *Source) SearchPageSummaries(cql string) ([]models.Page, error) {
+	return nil, ErrSearchUnsupported
+}
+
+// page returns the current page with the given ID
+func (s *Source) page(pageID string) (*content, error) {
+	page := s.entities.pages[pageID]
+	if page == nil || !page.current() {
+		return nil, fmt.Errorf("page %s is not in the space export", pageID)
+	}
+	return page, nil
+}
+
+// GetPage returns a single page by its ID
+func (s *Source) GetPage(pageID string) (*models.Page, error) {
+	page, err := s.page(pageID)
+	if err != nil {
+		return nil, err
+	}
+	result := s.toPage(page)
+	return &result, nil
+}
+
+// GetChildPages returns the direct child pages of a page
+func (s *Source) GetChildPages(parentPageID string) ([]models.Page, error) {
+	if _, err := s.page(parentPageID); err != nil {
+		return nil, err
+	}
+	var pages []models.Page
+	for _, child := range s.children[parentPageID] {
+		pages = append(pages, s.toPage(child))
+	}
+	return pages, nil
+}
+
+// GetPageVersions returns the version history of a page, newest first,
+// including the current version
+func (s *Source) GetPageVersions(pageID string) ([]models.PageVersion, error) {
+	page, err := s.page(pageID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []models.PageVersion{s.toVersion(page, false)}
+	for _, version := range s.history[pageID] {
+		versions = append(versions, s.toVersion(version, false))
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Number > versions[j].Number })
+	return versions, nil
+}
+
+// GetPageVersion returns a version of a page with its title and storage content
+func (s *Source) GetPageVersion(pageID string, number int) (*models.PageVersion, error) {
+	page, err := s.page(pageID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := append([]*content{page}, s.history[pageID]...)
+	for _, version := range candidates {
+		if version.version == number {
+			result := s.toVersion(version, true)
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("version %d of page %s is not in the space export", number, pageID)
+}
+
+// GetAttachments returns the current attachments of a page
+func (s *Source) GetAttachments(pageID string) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	for _, a := range s.attachments[pageID] {
+		properties := s.entities.properties[a.id]
+		size, _ := strconv.ParseInt(properties["FILESIZE"], 10, 64)
+		if size == 0 {
+			size = a.fileSize
+		}
+		mediaType := properties["MEDIA_TYPE"]
+		if mediaType == "" {
+			mediaType = a.mediaType
+		}
+
+		attachments = append(attachments, models.Attachment{
+			ID:          a.id,
+			Title:       a.title,
+			FileName:    a.title,
+			MediaType:   mediaType,
+			FileSize:    size,
+			DownloadURL: fmt.Sprintf("/download/attachments/%s/%s?version=%d", pageID, url.PathEscape(a.title), a.version),
+		})
+	}
+	return attachments, nil
+}
+
+// OpenAttachment opens the file of an attachment in the ZIP
+func (s *Source) OpenAttachment(attachment models.Attachment) (io.ReadCloser, error) {
+	f := s.files[attachment.ID]
+	if f == nil {
+		return nil, fmt.Errorf("file of attachment %s is not in the space export", attachment.ID)
+	}
+	return f.Open()
+}
+
+// toPage converts a current page of entities.xml to a page of the exporter
+func (s *Source) toPage(c *content) models.Page {
+	space := s.entities.spaces[c.spaceID]
+	page := models.Page{
+		ID:             c.id,
+		Title:          c.title,
+		SpaceKey:       space.key,
+		SpaceName:      space.name,
+		Version:        c.version,
+		Content:        s.entities.bodies[c.id],
+		CreatedAt:      c.created,
+		CreatedBy:      s.entities.user(c.creator),
+		UpdatedAt:      c.modified,
+		UpdatedBy:      s.entities.user(c.modifier),
+		VersionMessage: c.comment,
+	}
+	if s.baseURL != "" {
+		page.URL = s.baseURL + "/pages/viewpage.action?pageId=" + c.id
+	}
+
+	// Ancestors are listed from the root down, like the REST API does
+	for parent := s.entities.pages[c.parentID]; parent != nil && parent.current(); parent = s.entities.pages[parent.parentID] {
+		page.Ancestors = append([]models.Ancestor{{ID: parent.id, Title: parent.title}}, page.Ancestors...)
+	}
+	if len(page.Ancestors) > 0 {
+		page.ParentID = page.Ancestors[len(page.Ancestors)-1].ID
+	}
+
+	for _, labelID := range s.entities.labellings[c.id] {
+		if name, ok := s.entities.labels[labelID]; ok {
+			page.Labels = append(page.Labels, models.Label{ID: labelID, Name: name})
+		}
+	}
+	return page
+}
+
+// toVersion converts a page or a historical version to a page version,
+// withContent adds the title and storage content
+func (s *Source) toVersion(c *content, withContent bool) models.PageVersion {
+	version := models.PageVersion{
+		Number:    c.version,
+		CreatedAt: c.modified,
+		CreatedBy: s.entities.user(c.modifier),
+		Message:   c.comment,
+	}
+	if withContent {
+		version.Title = c.title
+		version.Content = s.entities.bodies[c.id]
+	}
+	return version
+}
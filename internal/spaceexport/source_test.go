@@ -0,0 +1,401 @@
+package spaceexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"confluence-exporter/internal/models"
+)
+
+// testEntities is the entities.xml of a space TEAM with the page tree
+//
+//	Home (v2, with version 1 in the history)
+//	├── Child A
+//	└── Child B
+//	    └── Grandchild
+//
+// and a trashed page, a draft, labels and attachments in the layout of newer
+// and older exports
+const testEntities = `<?xml version="1.0" encoding="UTF-8"?>
+<hibernate-generic datetime="2024-01-05 10:00:00">
+<object class="Space" package="com.atlassian.confluence.spaces">
+  <id name="id">98305</id>
+  <property name="name"><![CDATA[Team]]></property>
+  <property name="key"><![CDATA[TEAM]]></property>
+</object>
+<object class="ConfluenceUserImpl" package="com.atlassian.confluence.user">
+  <id name="key"><![CDATA[ff80aa]]></id>
+  <property name="name"><![CDATA[alice]]></property>
+</object>
+<object class="Page" package="com.atlassian.confluence.pages">
+  <id name="id">10</id>
+  <property name="title"><![CDATA[Home]]></property>
+  <property name="version">2</property>
+  <property name="contentStatus"><![CDATA[current]]></property>
+  <property name="space" class="Space" package="com.atlassian.confluence.spaces"><id name="id">98305</id></property>
+  <property name="creator" class="ConfluenceUserImpl" package="com.atlassian.confluence.user"><id name="key"><![CDATA[ff80aa]]></id></property>
+  <property name="creationDate">2023-05-01 08:30:00.000</property>
+  <property name="lastModifier" class="ConfluenceUserImpl" package="com.atlassian.confluence.user"><id name="key"><![CDATA[ff80aa]]></id></property>
+  <property name="lastModificationDate">2024-01-03 10:00:00.000</property>
+  <property name="versionComment"><![CDATA[second]]></property>
+</object>
+<object class="Page" package="com.atlassian.confluence.pages">
+  <id name="id">11</id>
+  <property name="title"><![CDATA[Welcome]]></property>
+  <property name="version">1</property>
+  <property name="contentStatus"><![CDATA[current]]></property>
+  <property name="originalVersion" class="Page" package="com.atlassian.confluence.pages"><id name="id">10</id></property>
+  <property name="space" class="Space" package="com.atlassian.confluence.spaces"><id name="id">98305</id></property>
+  <property name="lastModifierName"><![CDATA[bob]]></property>
+  <property name="lastModificationDate">2024-01-01 09:00:00</property>
+  <property name="versionComment"><![CDATA[first]]></property>
+</object>
+<object class="Page" package="com.atlassian.confluence.pages">
+  <id name="id">20</id>
+  <property name="title"><![CDATA[Child B]]></property>
+  <property name="version">1</property>
+  <property name="position">1</property>
+  <property name="parent" class="Page" package="com.atlassian.confluence.pages"><id name="id">10</id></property>
+  <property name="space" class="Space" package="com.atlassian.confluence.spaces"><id name="id">98305</id></property>
+</object>
+<object class="Page" package="com.atlassian.confluence.pages">
+  <id name="id">21</id>
+  <property name="title"><![CDATA[Child A]]></property>
+  <property name="version">1</property>
+  <property name="position">0</property>
+  <property name="parent" class="Page" package="com.atlassian.confluence.pages"><id name="id">10</id></property>
+  <property name="space" class="Space" package="com.atlassian.confluence.spaces"><id name="id">98305</id></property>
+</object>
+<object class="Page" package="com.atlassian.confluence.pages">
+  <id name="id">30</id>
+  <property name="title"><![CDATA[Grandchild]]></property>
+  <property name="version">1</property>
+  <property name="parent" class="Page" package="com.atlassian.confluence.pages"><id name="id">20</id></property>
+  <property name="space" class="Space" package="com.atlassian.confluence.spaces"><id name="id">98305</id></property>
+</object>
+<object class="Page" package="com.atlassian.confluence.pages">
+  <id name="id">40</id>
+  <property name="title"><![CDATA[Trashed]]></property>
+  <property name="version">1</property>
+  <property name="contentStatus"><![CDATA[deleted]]></property>
+  <property name="parent" class="Page" package="com.atlassian.confluence.pages"><id name="id">10</id></property>
+  <property name="space" class="Space" package="com.atlassian.confluence.spaces"><id name="id">98305</id></property>
+</object>
+<object class="Page" package="com.atlassian.confluence.pages">
+  <id name="id">50</id>
+  <property name="title"><![CDATA[Draft]]></property>
+  <property name="version">1</property>
+  <property name="contentStatus"><![CDATA[draft]]></property>
+  <property name="space" class="Space" package="com.atlassian.confluence.spaces"><id name="id">98305</id></property>
+</object>
+<object class="BodyContent" package="com.atlassian.confluence.core">
+  <id name="id">100</id>
+  <property name="body"><![CDATA[<p>Home v2</p>]]></property>
+  <property name="content" class="Page" package="com.atlassian.confluence.pages"><id name="id">10</id></property>
+  <property name="bodyType">2</property>
+</object>
+<object class="BodyContent" package="com.atlassian.confluence.core">
+  <id name="id">101</id>
+  <property name="body"><![CDATA[<p>Home v1</p>]]></property>
+  <property name="content" class="Page" package="com.atlassian.confluence.pages"><id name="id">11</id></property>
+  <property name="bodyType">2</property>
+</object>
+<object class="BodyContent" package="com.atlassian.confluence.core">
+  <id name="id">102</id>
+  <property name="body"><![CDATA[<p>B body</p>]]></property>
+  <property name="content" class="Page" package="com.atlassian.confluence.pages"><id name="id">20</id></property>
+  <property name="bodyType">2</property>
+</object>
+<object class="BodyContent" package="com.atlassian.confluence.core">
+  <id name="id">103</id>
+  <property name="body"><![CDATA[h1. Wiki markup]]></property>
+  <property name="content" class="Page" package="com.atlassian.confluence.pages"><id name="id">20</id></property>
+  <property name="bodyType">0</property>
+</object>
+<object class="Attachment" package="com.atlassian.confluence.pages">
+  <id name="id">200</id>
+  <property name="title"><![CDATA[diagram.png]]></property>
+  <property name="version">2</property>
+  <property name="containerContent" class="Page" package="com.atlassian.confluence.pages"><id name="id">10</id></property>
+</object>
+<object class="Attachment" package="com.atlassian.confluence.pages">
+  <id name="id">201</id>
+  <property name="title"><![CDATA[diagram.png]]></property>
+  <property name="version">1</property>
+  <property name="originalVersion" class="Attachment" package="com.atlassian.confluence.pages"><id name="id">200</id></property>
+  <property name="containerContent" class="Page" package="com.atlassian.confluence.pages"><id name="id">10</id></property>
+</object>
+<object class="Attachment" package="com.atlassian.confluence.pages">
+  <id name="id">210</id>
+  <property name="title"><![CDATA[notes.txt]]></property>
+  <property name="version">1</property>
+  <property name="contentType"><![CDATA[text/plain]]></property>
+  <property name="fileSize">11</property>
+  <property name="content" class="Page" package="com.atlassian.confluence.pages"><id name="id">21</id></property>
+</object>
+<object class="ContentProperty" package="com.atlassian.confluence.content">
+  <id name="id">300</id>
+  <property name="name"><![CDATA[MEDIA_TYPE]]></property>
+  <property name="stringValue"><![CDATA[image/png]]></property>
+  <property name="content" class="Attachment" package="com.atlassian.confluence.pages"><id name="id">200</id></property>
+</object>
+<object class="ContentProperty" package="com.atlassian.confluence.content">
+  <id name="id">301</id>
+  <property name="name"><![CDATA[FILESIZE]]></property>
+  <property name="longValue">4</property>
+  <property name="content" class="Attachment" package="com.atlassian.confluence.pages"><id name="id">200</id></property>
+</object>
+<object class="Label" package="com.atlassian.confluence.labels">
+  <id name="id">400</id>
+  <property name="name"><![CDATA[runbook]]></property>
+  <property name="namespace"><![CDATA[global]]></property>
+</object>
+<object class="Label" package="com.atlassian.confluence.labels">
+  <id name="id">401</id>
+  <property name="name"><![CDATA[favourite]]></property>
+  <property name="namespace"><![CDATA[my]]></property>
+</object>
+<object class="Labelling" package="com.atlassian.confluence.labels">
+  <id name="id">500</id>
+  <property name="label" class="Label" package="com.atlassian.confluence.labels"><id name="id">400</id></property>
+  <property name="content" class="Page" package="com.atlassian.confluence.pages"><id name="id">20</id></property>
+</object>
+<object class="Labelling" package="com.atlassian.confluence.labels">
+  <id name="id">501</id>
+  <property name="label" class="Label" package="com.atlassian.confluence.labels"><id name="id">401</id></property>
+  <property name="content" class="Page" package="com.atlassian.confluence.pages"><id name="id">20</id></property>
+</object>
+</hibernate-generic>
+`
+
+// openTestExport writes a space export ZIP with files, path to content, and
+// opens it
+func openTestExport(t *testing.T, files map[string]string) *Source {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "export.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := Open(path, "https://wiki.example.com/")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { source.Close() })
+	return source
+}
+
+func newTestSource(t *testing.T) *Source {
+	return openTestExport(t, map[string]string{
+		"entities.xml":                testEntities,
+		"attachments/10/200/2":        "PNG2",
+		"attachments/10/200/1":        "PNG1",
+		"attachments/21/210":          "hello notes",
+		"exportDescriptor.properties": "spaceKey=TEAM\n",
+	})
+}
+
+// titles returns the titles of pages joined by commas
+func titles(pages []models.Page) string {
+	var names []string
+	for _, page := range pages {
+		names = append(names, page.Title)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSourceHierarchy(t *testing.T) {
+	source := newTestSource(t)
+
+	spaces, err := source.GetSpaces()
+	if err != nil || len(spaces) != 1 || spaces[0].Key != "TEAM" || spaces[0].Name != "Team" {
+		t.Fatalf("GetSpaces = %+v, %v", spaces, err)
+	}
+
+	// Pages are listed depth first, siblings by position
+	var listed []models.Page
+	err = source.ListPages("TEAM", 0, func(pages []models.Page, next int) error {
+		listed = append(listed, pages...)
+		if next != 4 {
+			t.Errorf("next offset = %d, want 4", next)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListPages: %v", err)
+	}
+	if got := titles(listed); got != "Home,Child A,Child B,Grandchild" {
+		t.Errorf("listed %s, want the current pages depth first", got)
+	}
+
+	var rest []models.Page
+	source.ListPages("TEAM", 3, func(pages []models.Page, next int) error {
+		rest = append(rest, pages...)
+		return nil
+	})
+	if got := titles(rest); got != "Grandchild" {
+		t.Errorf("listed %s from offset 3, want Grandchild", got)
+	}
+
+	children, err := source.GetChildPages("10")
+	if err != nil || titles(children) != "Child A,Child B" {
+		t.Errorf("GetChildPages = %s, %v", titles(children), err)
+	}
+
+	page, err := source.GetPage("30")
+	if err != nil {
+		t.Fatalf("GetPage: %v", err)
+	}
+	if page.ParentID != "20" || fmt.Sprint(page.Ancestors) != "[{10 Home} {20 Child B}]" {
+		t.Errorf("page has parent %s and ancestors %v", page.ParentID, page.Ancestors)
+	}
+	if page.URL != "https://wiki.example.com/pages/viewpage.action?pageId=30" || page.SpaceName != "Team" {
+		t.Errorf("page = %+v", page)
+	}
+
+	for _, id := range []string{"11", "40", "50", "99"} {
+		if _, err := source.GetPage(id); err == nil {
+			t.Errorf("GetPage(%s) found a page that isn't current", id)
+		}
+	}
+}
+
+func TestSourcePageContent(t *testing.T) {
+	source := newTestSource(t)
+
+	home, err := source.GetPage("10")
+	if err != nil {
+		t.Fatalf("GetPage: %v", err)
+	}
+	if home.Content != "<p>Home v2</p>" || home.Version != 2 || home.VersionMessage != "second" {
+		t.Errorf("home = %+v", home)
+	}
+	if home.CreatedBy != "alice" || home.CreatedAt != "2023-05-01T08:30:00.000Z" || home.UpdatedAt != "2024-01-03T10:00:00.000Z" {
+		t.Errorf("home was created %s by %s and updated %s", home.CreatedAt, home.CreatedBy, home.UpdatedAt)
+	}
+
+	// Only storage format bodies are read, and personal labels are left out
+	child, err := source.GetPage("20")
+	if err != nil {
+		t.Fatalf("GetPage: %v", err)
+	}
+	if child.Content != "<p>B body</p>" {
+		t.Errorf("content = %q, want the storage format body", child.Content)
+	}
+	if fmt.Sprint(child.Labels) != "[{400 runbook}]" {
+		t.Errorf("labels = %v, want runbook only", child.Labels)
+	}
+}
+
+func TestSourceVersions(t *testing.T) {
+	source := newTestSource(t)
+
+	versions, err := source.GetPageVersions("10")
+	if err != nil {
+		t.Fatalf("GetPageVersions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Number != 2 || versions[1].Number != 1 {
+		t.Fatalf("versions = %+v, want 2 and 1", versions)
+	}
+	if versions[1].CreatedBy != "bob" || versions[1].CreatedAt != "2024-01-01T09:00:00.000Z" || versions[1].Content != "" {
+		t.Errorf("version 1 = %+v, want bob's version without content", versions[1])
+	}
+
+	old, err := source.GetPageVersion("10", 1)
+	if err != nil {
+		t.Fatalf("GetPageVersion: %v", err)
+	}
+	if old.Title != "Welcome" || old.Content != "<p>Home v1</p>" || old.Message != "first" {
+		t.Errorf("version 1 = %+v", old)
+	}
+	if _, err := source.GetPageVersion("10", 3); err == nil {
+		t.Errorf("GetPageVersion found a version that doesn't exist")
+	}
+}
+
+func TestSourceAttachments(t *testing.T) {
+	source := newTestSource(t)
+
+	tests := []struct {
+		pageID  string
+		want    models.Attachment
+		content string
+	}{
+		{
+			pageID:  "10",
+			want:    models.Attachment{ID: "200", Title: "diagram.png", FileName: "diagram.png", MediaType: "image/png", FileSize: 4, DownloadURL: "/download/attachments/10/diagram.png?version=2"},
+			content: "PNG2",
+		},
+		{
+			// Older exports store the file without a version directory
+			pageID:  "21",
+			want:    models.Attachment{ID: "210", Title: "notes.txt", FileName: "notes.txt", MediaType: "text/plain", FileSize: 11, DownloadURL: "/download/attachments/21/notes.txt?version=1"},
+			content: "hello notes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.FileName, func(t *testing.T) {
+			attachments, err := source.GetAttachments(tt.pageID)
+			if err != nil {
+				t.Fatalf("GetAttachments: %v", err)
+			}
+			if len(attachments) != 1 || attachments[0] != tt.want {
+				t.Fatalf("attachments = %+v, want %+v", attachments, tt.want)
+			}
+
+			r, err := source.OpenAttachment(attachments[0])
+			if err != nil {
+				t.Fatalf("OpenAttachment: %v", err)
+			}
+			defer r.Close()
+			data, _ := io.ReadAll(r)
+			if string(data) != tt.content {
+				t.Errorf("attachment content = %q, want %q", data, tt.content)
+			}
+		})
+	}
+
+	if _, err := source.OpenAttachment(models.Attachment{ID: "999"}); err == nil {
+		t.Errorf("OpenAttachment opened an attachment that isn't in the export")
+	}
+}
+
+func TestSourceSearchUnsupported(t *testing.T) {
+	source := newTestSource(t)
+
+	err := source.SearchPages("type = page", 0, func([]models.Page, int) error { return nil })
+	if !errors.Is(err, ErrSearchUnsupported) {
+		t.Errorf("SearchPages error = %v", err)
+	}
+}
+
+func TestOpenWithoutEntities(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	w.Create("readme.txt")
+	w.Close()
+
+	path := filepath.Join(t.TempDir(), "export.zip")
+	os.WriteFile(path, buf.Bytes(), 0644)
+	if _, err := Open(path, ""); err == nil || !strings.Contains(err.Error(), "entities.xml not found") {
+		t.Errorf("Open error = %v", err)
+	}
+}
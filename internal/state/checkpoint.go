@@ -0,0 +1,118 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CheckpointFileName is the name of the checkpoint file inside the output directory
+const CheckpointFileName = ".confluence-export-checkpoint.json"
+
+// ScopeCheckpoint is the progress made within one space or page tree
+type ScopeCheckpoint struct {
+	// Done is set once every page of the scope was handled
+	Done bool `json:"done"`
+	// Offset is the pagination offset of the next batch to list
+	Offset int `json:"offset"`
+	// Pages were saved (or skipped as unchanged) in the interrupted run
+	Pages []PageState `json:"pages,omitempty"`
+	// Failed pages were listed but could not be saved
+	Failed []PageState `json:"failed,omitempty"`
+}
+
+// Checkpoint records the progress of a running export so an interrupted
+// run can be resumed
+type Checkpoint struct {
+	UpdatedAt time.Time                   `json:"updatedAt"`
+	Scopes    map[string]*ScopeCheckpoint `json:"scopes"`
+	// Output is the position marker returned by the output handler
+	Output string `json:"output,omitempty"`
+
+	path string
+}
+
+// NewCheckpoint creates an empty checkpoint stored at path
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{Scopes: make(map[string]*ScopeCheckpoint), path: path}
+}
+
+// LoadCheckpoint reads the checkpoint at path. It returns nil without an
+// error if there is no checkpoint.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewCheckpoint(path)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %v", path, err)
+	}
+	if c.Scopes == nil {
+		c.Scopes = make(map[string]*ScopeCheckpoint)
+	}
+	return c, nil
+}
+
+// Scope returns the progress of scope, creating it if needed
+func (c *Checkpoint) Scope(scope string) *ScopeCheckpoint {
+	sc, ok := c.Scopes[scope]
+	if !ok {
+		sc = &ScopeCheckpoint{}
+		c.Scopes[scope] = sc
+	}
+	return sc
+}
+
+// Save atomically writes the checkpoint
+func (c *Checkpoint) Save() error {
+	c.UpdatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Remove deletes the checkpoint file after a completed run
+func (c *Checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Restore replays the pages saved by the interrupted run, recorded in sc,
+// into the tracker, so they count as seen and are kept in the next state
+// without being exported again. It returns the IDs of the restored pages.
+// Pages that failed are not restored: they are removed from sc and returned,
+// so they can be exported again.
+func (t *Tracker) Restore(scope string, sc *ScopeCheckpoint) (map[string]bool, []PageState) {
+	restored := make(map[string]bool, len(sc.Pages))
+	for _, entry := range sc.Pages {
+		t.Observe(scope, entry.Page())
+		t.Saved(scope, entry.Page())
+		restored[entry.ID] = true
+	}
+
+	failed := sc.Failed
+	sc.Failed = nil
+	return restored, failed
+}
@@ -0,0 +1,70 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+
+	"confluence-exporter/internal/models"
+)
+
+func TestCheckpointSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", CheckpointFileName)
+
+	if c, err := LoadCheckpoint(path); c != nil || err != nil {
+		t.Fatalf("LoadCheckpoint without a file = %v, %v, want nil, nil", c, err)
+	}
+
+	c := NewCheckpoint(path)
+	sc := c.Scope(SpaceScope("TEAM"))
+	sc.Offset = 50
+	sc.Pages = []PageState{{ID: "1", Title: "Home", Version: 2, Scope: SpaceScope("TEAM")}}
+	sc.Failed = []PageState{{ID: "2", Title: "Broken", Version: 1, Scope: SpaceScope("TEAM")}}
+	c.Output = "1234"
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint: %v", err)
+	}
+	got := loaded.Scope(SpaceScope("TEAM"))
+	if got.Offset != 50 || len(got.Pages) != 1 || len(got.Failed) != 1 || loaded.Output != "1234" {
+		t.Errorf("loaded scope %+v with output %q", got, loaded.Output)
+	}
+
+	if err := loaded.Remove(); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if c, _ := LoadCheckpoint(path); c != nil {
+		t.Errorf("checkpoint still exists after Remove")
+	}
+}
+
+func TestTrackerRestore(t *testing.T) {
+	scope := SpaceScope("TEAM")
+	sc := &ScopeCheckpoint{
+		Pages:  []PageState{{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 2, Scope: scope}},
+		Failed: []PageState{{ID: "2", Title: "Broken", SpaceKey: "TEAM", Version: 1, Scope: scope}},
+	}
+	tracker := NewTracker(New())
+
+	done, failed := tracker.Restore(scope, sc)
+	if len(done) != 1 || !done["1"] {
+		t.Errorf("restored %v, want page 1", done)
+	}
+	if len(failed) != 1 || failed[0].ID != "2" || sc.Failed != nil {
+		t.Errorf("Restore returned failed pages %v and left %v in the checkpoint", failed, sc.Failed)
+	}
+
+	// Restored pages are kept in the next state, failed ones are exported again
+	tracker.Observe(scope, models.Page{ID: "2", Title: "Broken", SpaceKey: "TEAM", Version: 1})
+	tracker.CompleteScope(scope)
+	next, report := tracker.Finish()
+	if _, ok := next.Pages["1"]; !ok {
+		t.Errorf("restored page is missing from the next state")
+	}
+	if len(report.Deleted) != 0 || len(report.Added) != 2 {
+		t.Errorf("report = %+v, want 2 added pages", report)
+	}
+}
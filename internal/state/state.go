@@ -0,0 +1,250 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"confluence-exporter/internal/models"
+)
+
+// FileName is the name of the sync state file inside the output directory
+const FileName = ".confluence-export-state.json"
+
+// PageState is the last exported state of a single page
+type PageState struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	SpaceKey string `json:"spaceKey"`
+	ParentID string `json:"parentId,omitempty"`
+	Version  int    `json:"version"`
+	// Scope identifies the export the page was found by (a space or page tree)
+	Scope string `json:"scope"`
+}
+
+// Page returns the page metadata recorded in the state
+func (p PageState) Page() models.Page {
+	return models.Page{
+		ID:       p.ID,
+		Title:    p.Title,
+		SpaceKey: p.SpaceKey,
+		ParentID: p.ParentID,
+		Version:  p.Version,
+	}
+}
+
+// movedFrom reports whether page lives somewhere else than recorded in p
+func (p PageState) movedFrom(page models.Page) bool {
+	if p.Title != page.Title || p.SpaceKey != page.SpaceKey {
+		return true
+	}
+	// The parent is not known for every listing, only compare it when both sides have it
+	return p.ParentID != "" && page.ParentID != "" && p.ParentID != page.ParentID
+}
+
+// State is the persisted result of the previous export run
+type State struct {
+	UpdatedAt time.Time            `json:"updatedAt"`
+	Pages     map[string]PageState `json:"pages"`
+}
+
+// New returns an empty state
+func New() *State {
+	return &State{Pages: make(map[string]PageState)}
+}
+
+// SpaceScope returns the scope name for an export of a whole space
+func SpaceScope(spaceKey string) string {
+	return "space:" + spaceKey
+}
+
+// TreeScope returns the scope name for an export of a page tree
+func TreeScope(rootPageID string) string {
+	return "tree:" + rootPageID
+}
+
+// QueryScope returns the scope name for an export of a CQL query
+func QueryScope(cql string) string {
+	return "cql:" + cql
+}
+
+// Load reads the state file at path. A missing file yields an empty state.
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := New()
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", path, err)
+	}
+	if s.Pages == nil {
+		s.Pages = make(map[string]PageState)
+	}
+	return s, nil
+}
+
+// Save atomically writes the state to path
+func (s *State) Save(path string) error {
+	s.UpdatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Change classifies a page compared to the previous run
+type Change int
+
+const (
+	Unchanged Change = iota
+	Added
+	Changed
+	// Moved pages kept their version but were renamed or moved to another parent or space
+	Moved
+)
+
+// Move is a page whose title, space or parent changed since the previous run
+type Move struct {
+	From PageState
+	To   PageState
+}
+
+// Report lists the differences between the previous and the current run
+type Report struct {
+	Added     []PageState
+	Changed   []PageState
+	Moved     []Move
+	Deleted   []PageState
+	Unchanged int
+}
+
+// Tracker compares the pages seen during a run with the previous state and
+// builds the state to persist for the next run. It is not safe for
+// concurrent use.
+type Tracker struct {
+	previous  *State
+	current   *State
+	seen      map[string]bool
+	completed map[string]bool
+	report    Report
+}
+
+// NewTracker creates a tracker comparing against previous
+func NewTracker(previous *State) *Tracker {
+	return &Tracker{
+		previous:  previous,
+		current:   New(),
+		seen:      make(map[string]bool),
+		completed: make(map[string]bool),
+	}
+}
+
+// Observe records that page still exists in scope and reports how it changed
+// since the previous run
+func (t *Tracker) Observe(scope string, page models.Page) Change {
+	t.seen[page.ID] = true
+
+	entry := NewPageState(scope, page)
+	prev, ok := t.previous.Pages[page.ID]
+	if !ok {
+		t.report.Added = append(t.report.Added, entry)
+		return Added
+	}
+
+	moved := prev.movedFrom(page)
+	if moved {
+		t.report.Moved = append(t.report.Moved, Move{From: prev, To: entry})
+	}
+
+	switch {
+	case page.Version > prev.Version:
+		t.report.Changed = append(t.report.Changed, entry)
+		return Changed
+	case moved:
+		return Moved
+	default:
+		t.report.Unchanged++
+		return Unchanged
+	}
+}
+
+// MovedFrom returns the previous location of page if it was moved or renamed
+func (t *Tracker) MovedFrom(page models.Page) (PageState, bool) {
+	prev, ok := t.previous.Pages[page.ID]
+	if !ok || !prev.movedFrom(page) {
+		return PageState{}, false
+	}
+	return prev, true
+}
+
+// PreviousVersion returns the version of page exported by the previous run, or
+// 0 if it is new. It only reads the previous state and may be called
+// concurrently.
+func (t *Tracker) PreviousVersion(pageID string) int {
+	return t.previous.Pages[pageID].Version
+}
+
+// Saved records that page was exported successfully (or is unchanged) in scope
+func (t *Tracker) Saved(scope string, page models.Page) {
+	t.current.Pages[page.ID] = NewPageState(scope, page)
+}
+
+// CompleteScope marks scope as fully listed, so pages of the previous run
+// that were not observed in it count as deleted
+func (t *Tracker) CompleteScope(scope string) {
+	t.completed[scope] = true
+}
+
+// Finish returns the state to persist and the change report. Pages of the
+// previous run that were not saved again are carried over, unless their scope
+// was completed without observing them.
+func (t *Tracker) Finish() (*State, Report) {
+	for id, prev := range t.previous.Pages {
+		if _, ok := t.current.Pages[id]; ok {
+			continue
+		}
+		if !t.seen[id] && t.completed[prev.Scope] {
+			t.report.Deleted = append(t.report.Deleted, prev)
+			continue
+		}
+		t.current.Pages[id] = prev
+	}
+
+	sort.Slice(t.report.Deleted, func(i, j int) bool {
+		return t.report.Deleted[i].ID < t.report.Deleted[j].ID
+	})
+
+	return t.current, t.report
+}
+
+// NewPageState records page as found in scope
+func NewPageState(scope string, page models.Page) PageState {
+	return PageState{
+		ID:       page.ID,
+		Title:    page.Title,
+		SpaceKey: page.SpaceKey,
+		ParentID: page.ParentID,
+		Version:  page.Version,
+		Scope:    scope,
+	}
+}
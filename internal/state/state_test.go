@@ -0,0 +1,200 @@
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"confluence-exporter/internal/models"
+)
+
+// previousState is the state of an earlier run with pages 1 and 2 in space
+// TEAM and page 3 in space OPS
+func previousState() *State {
+	s := New()
+	s.Pages["1"] = PageState{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 3, Scope: SpaceScope("TEAM")}
+	s.Pages["2"] = PageState{ID: "2", Title: "Guide", SpaceKey: "TEAM", ParentID: "1", Version: 1, Scope: SpaceScope("TEAM")}
+	s.Pages["3"] = PageState{ID: "3", Title: "Runbook", SpaceKey: "OPS", Version: 5, Scope: SpaceScope("OPS")}
+	return s
+}
+
+func TestTrackerObserve(t *testing.T) {
+	tests := []struct {
+		name string
+		page models.Page
+		want Change
+	}{
+		{name: "unchanged page", page: models.Page{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 3}, want: Unchanged},
+		{name: "version bump", page: models.Page{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 4}, want: Changed},
+		{name: "new page", page: models.Page{ID: "9", Title: "New", SpaceKey: "TEAM", Version: 1}, want: Added},
+		{name: "listing without parent", page: models.Page{ID: "2", Title: "Guide", SpaceKey: "TEAM", Version: 1}, want: Unchanged},
+		{name: "parent change", page: models.Page{ID: "2", Title: "Guide", SpaceKey: "TEAM", ParentID: "7", Version: 1}, want: Moved},
+		{name: "rename", page: models.Page{ID: "2", Title: "User Guide", SpaceKey: "TEAM", Version: 1}, want: Moved},
+		{name: "space change", page: models.Page{ID: "3", Title: "Runbook", SpaceKey: "TEAM", Version: 5}, want: Moved},
+		{name: "move with a version bump", page: models.Page{ID: "2", Title: "Guide", SpaceKey: "TEAM", ParentID: "7", Version: 2}, want: Changed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewTracker(previousState())
+			if got := tracker.Observe(SpaceScope("TEAM"), tt.page); got != tt.want {
+				t.Errorf("Observe = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackerFinish(t *testing.T) {
+	tests := []struct {
+		name string
+		// observe are the pages of space TEAM seen and saved in this run
+		observe []models.Page
+		// seen are pages that were listed but failed to save
+		seen     []models.Page
+		complete []string
+		wantKept []string
+		wantGone []string
+	}{
+		{
+			name:     "pages of a scope that wasn't completed are kept",
+			observe:  []models.Page{{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 3}},
+			wantKept: []string{"1", "2", "3"},
+		},
+		{
+			name:     "pages of other scopes are kept",
+			observe:  []models.Page{{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 3}, {ID: "2", Title: "Guide", SpaceKey: "TEAM", Version: 1}},
+			complete: []string{SpaceScope("TEAM")},
+			wantKept: []string{"1", "2", "3"},
+		},
+		{
+			name:     "pages missing from a completed scope are deleted",
+			observe:  []models.Page{{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 3}},
+			complete: []string{SpaceScope("TEAM")},
+			wantKept: []string{"1", "3"},
+			wantGone: []string{"2"},
+		},
+		{
+			name:     "pages seen without being saved are kept",
+			observe:  []models.Page{{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 3}},
+			seen:     []models.Page{{ID: "2", Title: "Guide", SpaceKey: "TEAM", Version: 2}},
+			complete: []string{SpaceScope("TEAM")},
+			wantKept: []string{"1", "2", "3"},
+		},
+		{
+			name:     "deletions are sorted by ID",
+			complete: []string{SpaceScope("TEAM"), SpaceScope("OPS")},
+			wantGone: []string{"1", "2", "3"},
+		},
+		{
+			name:     "new pages are added to the state",
+			observe:  []models.Page{{ID: "9", Title: "New", SpaceKey: "TEAM", Version: 1}},
+			wantKept: []string{"1", "2", "3", "9"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewTracker(previousState())
+			for _, page := range tt.observe {
+				tracker.Observe(SpaceScope("TEAM"), page)
+				tracker.Saved(SpaceScope("TEAM"), page)
+			}
+			for _, page := range tt.seen {
+				tracker.Observe(SpaceScope("TEAM"), page)
+			}
+			for _, scope := range tt.complete {
+				tracker.CompleteScope(scope)
+			}
+
+			next, report := tracker.Finish()
+			for _, id := range tt.wantKept {
+				if _, ok := next.Pages[id]; !ok {
+					t.Errorf("page %s is missing from the next state", id)
+				}
+			}
+			if len(next.Pages) != len(tt.wantKept) {
+				t.Errorf("next state has %d pages, want %d", len(next.Pages), len(tt.wantKept))
+			}
+			var gone []string
+			for _, page := range report.Deleted {
+				gone = append(gone, page.ID)
+			}
+			if fmt.Sprint(gone) != fmt.Sprint(tt.wantGone) {
+				t.Errorf("deleted %v, want %v", gone, tt.wantGone)
+			}
+		})
+	}
+}
+
+func TestTrackerReport(t *testing.T) {
+	tracker := NewTracker(previousState())
+	scope := SpaceScope("TEAM")
+	for _, page := range []models.Page{
+		{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 4},
+		{ID: "2", Title: "Guide", SpaceKey: "TEAM", Version: 1},
+		{ID: "9", Title: "New", SpaceKey: "TEAM", Version: 1},
+	} {
+		tracker.Observe(scope, page)
+		tracker.Saved(scope, page)
+	}
+
+	next, report := tracker.Finish()
+	if len(report.Added) != 1 || report.Added[0].ID != "9" {
+		t.Errorf("added = %v, want page 9", report.Added)
+	}
+	if len(report.Changed) != 1 || report.Changed[0].Version != 4 {
+		t.Errorf("changed = %v, want page 1 at version 4", report.Changed)
+	}
+	if report.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", report.Unchanged)
+	}
+	if got := next.Pages["1"].Version; got != 4 {
+		t.Errorf("next state has page 1 at version %d, want 4", got)
+	}
+	if got := tracker.PreviousVersion("1"); got != 3 {
+		t.Errorf("PreviousVersion = %d, want 3", got)
+	}
+}
+
+func TestTrackerMoves(t *testing.T) {
+	tracker := NewTracker(previousState())
+	moved := models.Page{ID: "2", Title: "User Guide", SpaceKey: "TEAM", ParentID: "7", Version: 1}
+
+	if _, ok := tracker.MovedFrom(models.Page{ID: "1", Title: "Home", SpaceKey: "TEAM", Version: 3}); ok {
+		t.Errorf("MovedFrom reports an unchanged page as moved")
+	}
+	if _, ok := tracker.MovedFrom(models.Page{ID: "9", Title: "New", SpaceKey: "TEAM"}); ok {
+		t.Errorf("MovedFrom reports a new page as moved")
+	}
+	from, ok := tracker.MovedFrom(moved)
+	if !ok || from.Title != "Guide" || from.ParentID != "1" {
+		t.Errorf("MovedFrom = %+v, %v, want the previous location", from, ok)
+	}
+
+	tracker.Observe(SpaceScope("TEAM"), moved)
+	tracker.Saved(SpaceScope("TEAM"), moved)
+	next, report := tracker.Finish()
+	if len(report.Moved) != 1 || report.Moved[0].From.Title != "Guide" || report.Moved[0].To.Title != "User Guide" {
+		t.Errorf("moved = %+v", report.Moved)
+	}
+	if got := next.Pages["2"]; got.Title != "User Guide" || got.ParentID != "7" {
+		t.Errorf("next state has page 2 at %+v, want the new location", got)
+	}
+}
+
+func TestStateSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", FileName)
+
+	if s, err := Load(path); err != nil || len(s.Pages) != 0 {
+		t.Fatalf("Load without a file = %v, %v, want an empty state", s, err)
+	}
+	if err := previousState().Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded.Pages) != 3 || loaded.Pages["2"] != previousState().Pages["2"] {
+		t.Errorf("loaded %+v", loaded.Pages)
+	}
+}